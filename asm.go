@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/assembler"
+)
+
+// asm is the asm command, it assembles a source file into a rom and a symbol file.
+func asm(args []string) error {
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	out := fs.String("o", "", "rom file (default the source file with the .ch8 extension)")
+	sym := fs.String("sym", "", "symbol file with the label addresses (default the source file with the .sym extension), - disables it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 asm [OPTIONS] FILE\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	src, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	p, err := assembler.Assemble(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	base := strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0)))
+	if *out == "" {
+		*out = base + ".ch8"
+	}
+	if err := ioutil.WriteFile(*out, p.ROM, 0644); err != nil {
+		return err
+	}
+	if *sym == "-" {
+		return nil
+	}
+	if *sym == "" {
+		*sym = base + ".sym"
+	}
+	f, err := os.Create(*sym)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteSymbols(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,311 @@
+// Package assembler turns CHIP-8 source in a subset of the Octo syntax into a rom.
+//
+// Supported are labels (: name), :const, :alias, :org, :byte, :call, numbers as data bytes, all CHIP-8,
+// SUPER-CHIP and XO-CHIP instructions, if ... then, if ... begin ... else ... end and loop ... while ... again.
+// Macros, :calc expressions and the comparison operators <, >, <= and >= are not supported.
+package assembler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Origin is the address the rom is loaded at.
+const Origin = 0x200
+
+// Program is an assembled rom with the addresses of its labels.
+type Program struct {
+	ROM    []byte
+	Labels map[string]uint16
+}
+
+type token struct {
+	text string
+	line int
+}
+
+type fixupKind int
+
+const (
+	fixupAddr fixupKind = iota // the low 12 bits of an opcode
+	fixupLong                  // the 16 bit operand of i := long
+)
+
+// fixup is an operand referring to a label that was not defined yet when it was assembled.
+type fixup struct {
+	offset int
+	tok    token
+	kind   fixupKind
+}
+
+// block is an open if ... begin or loop, its jumps are patched when it is closed.
+type block struct {
+	loop   bool
+	start  uint16 // address of the loop
+	jumps  []int  // offsets of the jumps to the end of the block
+	tok    token
+	inElse bool
+}
+
+type assembler struct {
+	tokens  []token
+	pos     int
+	rom     []byte
+	offset  int // where the next byte is written, :org moves it
+	labels  map[string]uint16
+	consts  map[string]int
+	aliases map[string]byte
+	fixups  []fixup
+	blocks  []block
+}
+
+// Assemble assembles the source, errors report the line they were found on.
+func Assemble(src string) (*Program, error) {
+	a := &assembler{
+		tokens:  tokenize(src),
+		labels:  make(map[string]uint16),
+		consts:  make(map[string]int),
+		aliases: make(map[string]byte),
+	}
+	for !a.done() {
+		if err := a.statement(); err != nil {
+			return nil, err
+		}
+	}
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+		return nil, SyntaxError{Line: b.tok.line, Token: b.tok.text, Msg: "block is never closed"}
+	}
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.tok.text]
+		if !ok {
+			return nil, UndefinedError{Line: f.tok.line, Name: f.tok.text}
+		}
+		if err := a.patch(f.offset, f.kind, int(addr), f.tok); err != nil {
+			return nil, err
+		}
+	}
+	return &Program{ROM: a.rom, Labels: a.labels}, nil
+}
+
+// WriteSymbols writes the labels sorted by address, one "0x0202 name" per line.
+func (p *Program) WriteSymbols(w io.Writer) error {
+	names := make([]string, 0, len(p.Labels))
+	for name := range p.Labels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if p.Labels[names[i]] != p.Labels[names[j]] {
+			return p.Labels[names[i]] < p.Labels[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "0x%04X %s\n", p.Labels[name], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tokenize(src string) []token {
+	var tokens []token
+	for i, line := range strings.Split(src, "\n") {
+		if c := strings.Index(line, "#"); c >= 0 {
+			line = line[:c]
+		}
+		for _, text := range strings.Fields(line) {
+			tokens = append(tokens, token{text: text, line: i + 1})
+		}
+	}
+	return tokens
+}
+
+func (a *assembler) done() bool {
+	return a.pos >= len(a.tokens)
+}
+
+func (a *assembler) next() (token, error) {
+	if a.done() {
+		line := 0
+		if len(a.tokens) > 0 {
+			line = a.tokens[len(a.tokens)-1].line
+		}
+		return token{}, SyntaxError{Line: line, Msg: "unexpected end of source"}
+	}
+	t := a.tokens[a.pos]
+	a.pos++
+	return t, nil
+}
+
+// peek returns the text of the next token, or an empty string at the end of the source.
+func (a *assembler) peek() string {
+	if a.done() {
+		return ""
+	}
+	return a.tokens[a.pos].text
+}
+
+func (a *assembler) expect(text string) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if t.text != text {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "expected " + text}
+	}
+	return nil
+}
+
+func (a *assembler) here() uint16 {
+	return uint16(Origin + a.offset)
+}
+
+func (a *assembler) emit(b ...byte) {
+	for _, v := range b {
+		if a.offset < len(a.rom) {
+			a.rom[a.offset] = v
+		} else {
+			for len(a.rom) < a.offset {
+				a.rom = append(a.rom, 0)
+			}
+			a.rom = append(a.rom, v)
+		}
+		a.offset++
+	}
+}
+
+func (a *assembler) op(opcode uint16) {
+	a.emit(byte(opcode>>8), byte(opcode))
+}
+
+// opAddr emits an opcode with a 12 bit address operand, labels that are not defined yet are patched at the end.
+func (a *assembler) opAddr(opcode uint16, t token) error {
+	offset := a.offset
+	a.op(opcode)
+	return a.operand(offset, fixupAddr, t)
+}
+
+func (a *assembler) operand(offset int, kind fixupKind, t token) error {
+	if v, ok := a.known(t.text); ok {
+		return a.patch(offset, kind, v, t)
+	}
+	if !isName(t.text) {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "expected an address"}
+	}
+	a.fixups = append(a.fixups, fixup{offset: offset, tok: t, kind: kind})
+	return nil
+}
+
+func (a *assembler) patch(offset int, kind fixupKind, v int, t token) error {
+	switch kind {
+	case fixupLong:
+		if v < 0 || v > 0xFFFF {
+			return RangeError{Line: t.line, Token: t.text, Max: 0xFFFF}
+		}
+		a.rom[offset] = byte(v >> 8)
+		a.rom[offset+1] = byte(v)
+	default:
+		if v < 0 || v > 0xFFF {
+			return RangeError{Line: t.line, Token: t.text, Max: 0xFFF}
+		}
+		a.rom[offset] = a.rom[offset]&0xF0 | byte(v>>8)
+		a.rom[offset+1] = byte(v)
+	}
+	return nil
+}
+
+// known returns the value of a number, constant or label that is already defined.
+func (a *assembler) known(text string) (int, bool) {
+	if v, ok := a.consts[text]; ok {
+		return v, true
+	}
+	if v, ok := a.labels[text]; ok {
+		return int(v), true
+	}
+	v, err := parseNumber(text)
+	return v, err == nil
+}
+
+func parseNumber(text string) (int, error) {
+	neg := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+	var v uint64
+	var err error
+	switch {
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		v, err = strconv.ParseUint(text[2:], 16, 32)
+	case strings.HasPrefix(text, "0b") || strings.HasPrefix(text, "0B"):
+		v, err = strconv.ParseUint(text[2:], 2, 32)
+	default:
+		v, err = strconv.ParseUint(text, 10, 32)
+	}
+	if neg {
+		return -int(v), err
+	}
+	return int(v), err
+}
+
+func isName(text string) bool {
+	if text == "" || strings.ContainsAny(text[:1], "0123456789-:") {
+		return false
+	}
+	return !strings.ContainsAny(text, "{}")
+}
+
+// value reads a number or constant in the range min to max, negative numbers down to -(max+1)/2 wrap.
+func (a *assembler) value(max int) (int, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := a.known(t.text)
+	if !ok {
+		return 0, SyntaxError{Line: t.line, Token: t.text, Msg: "expected a number"}
+	}
+	if v < -(max+1)/2 || v > max {
+		return 0, RangeError{Line: t.line, Token: t.text, Max: max}
+	}
+	return v & max, nil
+}
+
+// register returns the register number of v0-vf or an alias.
+func (a *assembler) register(text string) (byte, bool) {
+	if r, ok := a.aliases[text]; ok {
+		return r, true
+	}
+	if len(text) == 2 && (text[0] == 'v' || text[0] == 'V') {
+		r, err := strconv.ParseUint(text[1:], 16, 8)
+		return byte(r), err == nil
+	}
+	return 0, false
+}
+
+func (a *assembler) nextRegister() (byte, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	r, ok := a.register(t.text)
+	if !ok {
+		return 0, SyntaxError{Line: t.line, Token: t.text, Msg: "expected a register"}
+	}
+	return r, nil
+}
+
+// define checks that a label or constant can be defined with the name.
+func (a *assembler) define(name token) error {
+	if !isName(name.text) {
+		return SyntaxError{Line: name.line, Token: name.text, Msg: "invalid name"}
+	}
+	if _, ok := a.labels[name.text]; ok {
+		return DuplicateError{Line: name.line, Name: name.text}
+	}
+	if _, ok := a.consts[name.text]; ok {
+		return DuplicateError{Line: name.line, Name: name.text}
+	}
+	return nil
+}
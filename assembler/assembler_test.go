@@ -0,0 +1,127 @@
+package assembler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		rom  []byte
+	}{
+		{"label", ": main\n  clear\n  jump main", []byte{0x00, 0xE0, 0x12, 0x00}},
+		{"forward reference", "jump main\n: main\n  i := data\n: data 0xFF", []byte{0x12, 0x02, 0xA2, 0x04, 0xFF}},
+		{"call", ": f return\n: main f :call f", []byte{0x00, 0xEE, 0x22, 0x00, 0x22, 0x00}},
+		{"alias", ":alias x v3\n:alias y vA\nx := 5\nx += y\nsprite x y 0", []byte{0x63, 0x05, 0x83, 0xA4, 0xD3, 0xA0}},
+		{"const", ":const speed 0x12\nv0 := speed\nv2 -= 1", []byte{0x60, 0x12, 0x72, 0xFF}},
+		{"const address", ":const screen 0x300\ni := screen\njump screen", []byte{0xA3, 0x00, 0x13, 0x00}},
+		{"if then", "if v0 == 5 then v1 := 1\nif v0 != v2 then clear", []byte{0x40, 0x05, 0x61, 0x01, 0x50, 0x20, 0x00, 0xE0}},
+		{"if begin else end", "if v0 key begin\n  v1 := 1\nelse\n  v1 := 2\nend",
+			[]byte{0xE0, 0x9E, 0x12, 0x08, 0x61, 0x01, 0x12, 0x0A, 0x61, 0x02}},
+		{"loop while again", "loop\n  v0 += 1\n  while v0 != 10\nagain\nclear",
+			[]byte{0x70, 0x01, 0x40, 0x0A, 0x12, 0x08, 0x12, 0x00, 0x00, 0xE0}},
+		{"nested loops", "loop\n  loop\n    while v1 == 0\n  again\n  while v0 == 0\nagain",
+			[]byte{0x31, 0x00, 0x12, 0x06, 0x12, 0x00, 0x30, 0x00, 0x12, 0x0C, 0x12, 0x00}},
+		{"long", "i := long data\n: data 1", []byte{0xF0, 0x00, 0x02, 0x04, 0x01}},
+		{"org", ":org 0x204 clear\n:org 0x200 return", []byte{0x00, 0xEE, 0x00, 0x00, 0x00, 0xE0}},
+		{"comment", "clear # jump nowhere\n# return", []byte{0x00, 0xE0}},
+	} {
+		prog, err := Assemble(tc.src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(prog.ROM, tc.rom) {
+			t.Errorf("%s: assembled % X, want % X", tc.name, prog.ROM, tc.rom)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	prog, err := Assemble(": main\n  clear\n: loop\n  jump loop\n:const five 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog.Labels) != 2 || prog.Labels["main"] != 0x200 || prog.Labels["loop"] != 0x202 {
+		t.Errorf("labels %v, want main at 200 and loop at 202", prog.Labels)
+	}
+	var sym strings.Builder
+	if err := prog.WriteSymbols(&sym); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sym.String(), "0x0200 main\n0x0202 loop\n"; got != want {
+		t.Errorf("symbols %q, want %q", got, want)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		line int
+		err  error
+	}{
+		{"clear\nv0 := 256", 2, RangeError{}},
+		{"v0 := -129", 1, RangeError{}},
+		{"sprite v0 v1 16", 1, RangeError{}},
+		{"\n\njump 0x1000", 3, RangeError{}},
+		{"i := long 0x10000", 1, RangeError{}},
+		{":org 0x100", 1, RangeError{}},
+		{"300", 1, RangeError{}},
+		{"clear\njump nowhere", 2, UndefinedError{}},
+		{": main\n: main", 2, DuplicateError{}},
+		{":const a 1\n: a", 2, DuplicateError{}},
+		{"v0 := vz", 1, SyntaxError{}},
+		{"vg := 1", 1, SyntaxError{}},
+		{"v0 *= v1", 1, SyntaxError{}},
+		{"loop\nv0 += 1", 1, SyntaxError{}},
+		{"clear\nagain", 2, SyntaxError{}},
+		{"while v0 == 1", 1, SyntaxError{}},
+		{"end", 1, SyntaxError{}},
+		{"if v0 == 1 clear", 1, SyntaxError{}},
+		{":macro foo { clear }", 1, SyntaxError{}},
+	} {
+		_, err := Assemble(tc.src)
+		if err == nil {
+			t.Errorf("%q assembled", tc.src)
+			continue
+		}
+		if got := fmt.Sprintf("%T", err); got != fmt.Sprintf("%T", tc.err) {
+			t.Errorf("%q: %v is a %s, want a %T", tc.src, err, got, tc.err)
+		}
+		if prefix := fmt.Sprintf("line %d: ", tc.line); !strings.HasPrefix(err.Error(), prefix) {
+			t.Errorf("%q: %q, want it on line %d", tc.src, err, tc.line)
+		}
+	}
+}
+
+// TestJumpsAboveAddressSpace checks that loops and blocks past 0xFFF, where jumps can not reach, fail to assemble
+// instead of writing the address into the opcode.
+func TestJumpsAboveAddressSpace(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		line int
+	}{
+		{":org 0x1000\nloop\n  clear\nagain", 4},
+		{":org 0xFF8\nloop\n  while v0 == 1\n  clear\n  clear\n  clear\nagain", 7},
+		{":org 0xFFA\nif v0 == 1 begin\n  clear\n  clear\nend", 5},
+		{":org 0xFFA\nif v0 == 1 begin\n  clear\nelse\n  clear\nend", 4},
+	} {
+		_, err := Assemble(tc.src)
+		var r RangeError
+		if !errors.As(err, &r) || r.Line != tc.line {
+			t.Errorf("%q: %v, want a range error on line %d", tc.src, err, tc.line)
+		}
+	}
+	prog, err := Assemble(":org 0xFF0\nloop\n  while v0 == 1\nagain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prog.ROM[len(prog.ROM)-4:]; !bytes.Equal(got, []byte{0x1F, 0xF6, 0x1F, 0xF0}) {
+		t.Errorf("jumps below 0x1000 assembled to % X", got)
+	}
+}
@@ -0,0 +1,45 @@
+package assembler
+
+import "fmt"
+
+type SyntaxError struct {
+	Line  int
+	Token string
+	Msg   string
+}
+
+func (e SyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Token, e.Msg)
+}
+
+type UndefinedError struct {
+	Line int
+	Name string
+}
+
+func (e UndefinedError) Error() string {
+	return fmt.Sprintf("line %d: undefined label: %s", e.Line, e.Name)
+}
+
+type DuplicateError struct {
+	Line int
+	Name string
+}
+
+func (e DuplicateError) Error() string {
+	return fmt.Sprintf("line %d: %s is already defined", e.Line, e.Name)
+}
+
+type RangeError struct {
+	Line  int
+	Token string
+	Min   int
+	Max   int
+}
+
+func (e RangeError) Error() string {
+	return fmt.Sprintf("line %d: %s is out of range (0x%X-0x%X)", e.Line, e.Token, e.Min, e.Max)
+}
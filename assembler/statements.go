@@ -0,0 +1,441 @@
+package assembler
+
+func (a *assembler) statement() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if r, ok := a.register(t.text); ok {
+		return a.registerStatement(r)
+	}
+	switch t.text {
+	case ":":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.define(name); err != nil {
+			return err
+		}
+		a.labels[name.text] = a.here()
+	case ":const":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.define(name); err != nil {
+			return err
+		}
+		v, err := a.value(0xFFFF)
+		if err != nil {
+			return err
+		}
+		a.consts[name.text] = v
+	case ":alias":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if !isName(name.text) {
+			return SyntaxError{Line: name.line, Token: name.text, Msg: "invalid name"}
+		}
+		r, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.aliases[name.text] = r
+	case ":org":
+		v, err := a.value(0xFFFF)
+		if err != nil {
+			return err
+		}
+		if v < Origin {
+			return RangeError{Line: t.line, Token: t.text, Min: Origin, Max: 0xFFFF}
+		}
+		a.offset = v - Origin
+	case ":byte":
+		v, err := a.value(0xFF)
+		if err != nil {
+			return err
+		}
+		a.emit(byte(v))
+	case ":call":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		return a.opAddr(0x2000, target)
+	case ":breakpoint", ":monitor":
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "not supported"}
+	case "clear":
+		a.op(0x00E0)
+	case "return", ";":
+		a.op(0x00EE)
+	case "exit":
+		a.op(0x00FD)
+	case "lores":
+		a.op(0x00FE)
+	case "hires":
+		a.op(0x00FF)
+	case "scroll-left":
+		a.op(0x00FC)
+	case "scroll-right":
+		a.op(0x00FB)
+	case "scroll-down", "scroll-up":
+		n, err := a.value(0xF)
+		if err != nil {
+			return err
+		}
+		if t.text == "scroll-down" {
+			a.op(0x00C0 | uint16(n))
+		} else {
+			a.op(0x00D0 | uint16(n))
+		}
+	case "jump", "jump0":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		if t.text == "jump" {
+			return a.opAddr(0x1000, target)
+		}
+		return a.opAddr(0xB000, target)
+	case "sprite":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		y, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		n, err := a.value(0xF)
+		if err != nil {
+			return err
+		}
+		a.op(0xD000 | uint16(x)<<8 | uint16(y)<<4 | uint16(n))
+	case "bcd", "saveflags", "loadflags":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.op(map[string]uint16{"bcd": 0xF033, "saveflags": 0xF075, "loadflags": 0xF085}[t.text] | uint16(x)<<8)
+	case "save", "load":
+		return a.saveLoad(t)
+	case "plane":
+		n, err := a.value(0xF)
+		if err != nil {
+			return err
+		}
+		a.op(0xF001 | uint16(n)<<8)
+	case "audio":
+		a.op(0xF002)
+	case "delay", "buzzer", "pitch":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.op(map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[t.text] | uint16(x)<<8)
+	case "i":
+		return a.indexStatement()
+	case "if":
+		return a.ifStatement(t)
+	case "else":
+		return a.elseStatement(t)
+	case "end":
+		return a.endStatement(t)
+	case "loop":
+		a.blocks = append(a.blocks, block{loop: true, start: a.here(), tok: t})
+	case "while":
+		return a.whileStatement(t)
+	case "again":
+		return a.againStatement(t)
+	default:
+		if v, err := parseNumber(t.text); err == nil {
+			if v < -0x80 || v > 0xFF {
+				return RangeError{Line: t.line, Token: t.text, Max: 0xFF}
+			}
+			a.emit(byte(v))
+			return nil
+		}
+		if isName(t.text) && t.text[0] != ':' {
+			return a.opAddr(0x2000, t) // a bare label calls it
+		}
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "unknown statement"}
+	}
+	return nil
+}
+
+func (a *assembler) registerStatement(x byte) error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+	vx := uint16(x) << 8
+	src, err := a.next()
+	if err != nil {
+		return err
+	}
+	y, isReg := a.register(src.text)
+	vy := uint16(y) << 4
+	switch opTok.text {
+	case ":=":
+		switch {
+		case isReg:
+			a.op(0x8000 | vx | vy)
+		case src.text == "random":
+			n, err := a.value(0xFF)
+			if err != nil {
+				return err
+			}
+			a.op(0xC000 | vx | uint16(n))
+		case src.text == "delay":
+			a.op(0xF007 | vx)
+		case src.text == "key":
+			a.op(0xF00A | vx)
+		default:
+			a.pos--
+			n, err := a.value(0xFF)
+			if err != nil {
+				return err
+			}
+			a.op(0x6000 | vx | uint16(n))
+		}
+		return nil
+	case "+=", "-=":
+		if isReg {
+			if opTok.text == "+=" {
+				a.op(0x8004 | vx | vy)
+			} else {
+				a.op(0x8005 | vx | vy)
+			}
+			return nil
+		}
+		a.pos--
+		n, err := a.value(0xFF)
+		if err != nil {
+			return err
+		}
+		if opTok.text == "-=" {
+			n = -n & 0xFF
+		}
+		a.op(0x7000 | vx | uint16(n))
+		return nil
+	}
+	ops := map[string]uint16{"|=": 0x1, "&=": 0x2, "^=": 0x3, ">>=": 0x6, "=-": 0x7, "<<=": 0xE}
+	n, ok := ops[opTok.text]
+	if !ok {
+		return SyntaxError{Line: opTok.line, Token: opTok.text, Msg: "unknown operator"}
+	}
+	if !isReg {
+		return SyntaxError{Line: src.line, Token: src.text, Msg: "expected a register"}
+	}
+	a.op(0x8000 | vx | vy | n)
+	return nil
+}
+
+func (a *assembler) indexStatement() error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+	if opTok.text == "+=" {
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.op(0xF01E | uint16(x)<<8)
+		return nil
+	}
+	if opTok.text != ":=" {
+		return SyntaxError{Line: opTok.line, Token: opTok.text, Msg: "expected := or +="}
+	}
+	src, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch src.text {
+	case "hex", "bighex":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		if src.text == "hex" {
+			a.op(0xF029 | uint16(x)<<8)
+		} else {
+			a.op(0xF030 | uint16(x)<<8)
+		}
+		return nil
+	case "long":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		a.op(0xF000)
+		offset := a.offset
+		a.op(0)
+		return a.operand(offset, fixupLong, target)
+	}
+	return a.opAddr(0xA000, src)
+}
+
+func (a *assembler) saveLoad(t token) error {
+	x, err := a.nextRegister()
+	if err != nil {
+		return err
+	}
+	if a.peek() != "-" {
+		if t.text == "save" {
+			a.op(0xF055 | uint16(x)<<8)
+		} else {
+			a.op(0xF065 | uint16(x)<<8)
+		}
+		return nil
+	}
+	a.pos++
+	y, err := a.nextRegister()
+	if err != nil {
+		return err
+	}
+	if t.text == "save" {
+		a.op(0x5002 | uint16(x)<<8 | uint16(y)<<4)
+	} else {
+		a.op(0x5003 | uint16(x)<<8 | uint16(y)<<4)
+	}
+	return nil
+}
+
+// condition reads a condition and returns the opcode skipping the next instruction when the condition does
+// not hold and the one skipping it when it holds.
+func (a *assembler) condition() (skipUnless uint16, skipIf uint16, err error) {
+	x, err := a.nextRegister()
+	if err != nil {
+		return 0, 0, err
+	}
+	vx := uint16(x) << 8
+	opTok, err := a.next()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch opTok.text {
+	case "key":
+		return 0xE0A1 | vx, 0xE09E | vx, nil
+	case "-key":
+		return 0xE09E | vx, 0xE0A1 | vx, nil
+	case "==", "!=":
+		src, err := a.next()
+		if err != nil {
+			return 0, 0, err
+		}
+		var equal, notEqual uint16 // skip when equal, skip when not equal
+		if y, ok := a.register(src.text); ok {
+			equal, notEqual = 0x5000|vx|uint16(y)<<4, 0x9000|vx|uint16(y)<<4
+		} else {
+			a.pos--
+			n, err := a.value(0xFF)
+			if err != nil {
+				return 0, 0, err
+			}
+			equal, notEqual = 0x3000|vx|uint16(n), 0x4000|vx|uint16(n)
+		}
+		if opTok.text == "==" {
+			return notEqual, equal, nil
+		}
+		return equal, notEqual, nil
+	}
+	return 0, 0, SyntaxError{Line: opTok.line, Token: opTok.text, Msg: "expected ==, !=, key or -key"}
+}
+
+func (a *assembler) ifStatement(t token) error {
+	skipUnless, skipIf, err := a.condition()
+	if err != nil {
+		return err
+	}
+	kind, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch kind.text {
+	case "then":
+		a.op(skipUnless)
+	case "begin":
+		a.op(skipIf)
+		a.blocks = append(a.blocks, block{jumps: []int{a.offset}, tok: t})
+		a.op(0x1000)
+	default:
+		return SyntaxError{Line: kind.line, Token: kind.text, Msg: "expected then or begin"}
+	}
+	return nil
+}
+
+func (a *assembler) elseStatement(t token) error {
+	if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].loop || a.blocks[len(a.blocks)-1].inElse {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "else without if ... begin"}
+	}
+	b := &a.blocks[len(a.blocks)-1]
+	jump := a.offset
+	a.op(0x1000)
+	if err := a.patchJumps(b.jumps, a.here(), t); err != nil {
+		return err
+	}
+	b.jumps = []int{jump}
+	b.inElse = true
+	return nil
+}
+
+func (a *assembler) endStatement(t token) error {
+	if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].loop {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "end without if ... begin"}
+	}
+	b := a.blocks[len(a.blocks)-1]
+	a.blocks = a.blocks[:len(a.blocks)-1]
+	return a.patchJumps(b.jumps, a.here(), t)
+}
+
+func (a *assembler) whileStatement(t token) error {
+	loop := -1
+	for i := len(a.blocks) - 1; i >= 0; i-- {
+		if a.blocks[i].loop {
+			loop = i
+			break
+		}
+	}
+	if loop < 0 {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "while outside of loop"}
+	}
+	_, skipIf, err := a.condition()
+	if err != nil {
+		return err
+	}
+	a.op(skipIf)
+	a.blocks[loop].jumps = append(a.blocks[loop].jumps, a.offset)
+	a.op(0x1000)
+	return nil
+}
+
+func (a *assembler) againStatement(t token) error {
+	if len(a.blocks) == 0 || !a.blocks[len(a.blocks)-1].loop {
+		return SyntaxError{Line: t.line, Token: t.text, Msg: "again without loop"}
+	}
+	b := a.blocks[len(a.blocks)-1]
+	a.blocks = a.blocks[:len(a.blocks)-1]
+	jump := a.offset
+	a.op(0x1000)
+	if err := a.patch(jump, fixupAddr, int(b.start), t); err != nil {
+		return err
+	}
+	return a.patchJumps(b.jumps, a.here(), t)
+}
+
+// patchJumps points the jumps out of a block at the target, t is the statement closing the block.
+func (a *assembler) patchJumps(jumps []int, target uint16, t token) error {
+	for _, offset := range jumps {
+		if err := a.patch(offset, fixupAddr, int(target), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
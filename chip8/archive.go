@@ -0,0 +1,83 @@
+package chip8
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// romExtensions are the extensions roms are distributed with, they tell the roms in an archive apart from the
+// readmes and screenshots next to them.
+var romExtensions = map[string]bool{".ch8": true, ".c8": true, ".sc8": true, ".xo8": true, ".ch10": true, ".hc8": true}
+
+// SplitArchivePath splits "games.zip:pong.ch8" in the archive and the name of the rom in it, entry is empty when
+// the path does not name a rom in the archive.
+func SplitArchivePath(file string) (archive, entry string) {
+	if i := strings.Index(strings.ToLower(file), ".zip:"); i >= 0 {
+		return file[:i+len(".zip")], file[i+len(".zip:"):]
+	}
+	return file, ""
+}
+
+// IsArchive reports whether the file is a zip archive, judged by its extension.
+func IsArchive(file string) bool {
+	archive, _ := SplitArchivePath(file)
+	return strings.EqualFold(path.Ext(archive), ".zip")
+}
+
+// ArchiveROMs lists the roms in a zip archive, the files with a rom extension or all files when none has one.
+func ArchiveROMs(archive string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return archiveROMs(r.File), nil
+}
+
+func archiveROMs(files []*zip.File) []string {
+	var roms, all []string
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		all = append(all, f.Name)
+		if romExtensions[strings.ToLower(path.Ext(f.Name))] {
+			roms = append(roms, f.Name)
+		}
+	}
+	if len(roms) == 0 {
+		return all
+	}
+	return roms
+}
+
+// readArchive reads a rom from a zip archive, the named entry or the only rom in it.
+func readArchive(archive, entry string) (name string, data []byte, err error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+	if entry == "" {
+		roms := archiveROMs(r.File)
+		if len(roms) != 1 {
+			return "", nil, ArchiveROMError{Archive: archive, ROMs: roms}
+		}
+		entry = roms[0]
+	}
+	for _, f := range r.File {
+		if f.Name != entry {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		return entry, data, err
+	}
+	return "", nil, NotInArchiveError{Archive: archive, Name: entry}
+}
@@ -0,0 +1,105 @@
+package chip8
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeArchive writes a zip archive with the files to the test directory, names ending in / are directories.
+func writeArchive(t *testing.T, files ...string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "games.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, name := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		if _, err := fw.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	for _, tc := range []struct{ file, archive, entry string }{
+		{"games.zip", "games.zip", ""},
+		{"games.zip:pong.ch8", "games.zip", "pong.ch8"},
+		{"dir/Games.ZIP:sub/pong.ch8", "dir/Games.ZIP", "sub/pong.ch8"},
+		{"pong.ch8", "pong.ch8", ""},
+		{"c:/roms/pong.ch8", "c:/roms/pong.ch8", ""},
+	} {
+		if archive, entry := SplitArchivePath(tc.file); archive != tc.archive || entry != tc.entry {
+			t.Errorf("%q split in %q and %q", tc.file, archive, entry)
+		}
+	}
+}
+
+func TestArchiveROMs(t *testing.T) {
+	for _, tc := range []struct {
+		files []string
+		roms  []string
+	}{
+		{[]string{"readme.txt", "pong.ch8", "sub/", "sub/Tetris.SC8", "shot.png"}, []string{"pong.ch8", "sub/Tetris.SC8"}},
+		{[]string{"readme.txt", "pong"}, []string{"readme.txt", "pong"}},
+		{[]string{"sub/"}, nil},
+	} {
+		roms, err := ArchiveROMs(writeArchive(t, tc.files...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(roms, tc.roms) {
+			t.Errorf("roms of %q: %q, want %q", tc.files, roms, tc.roms)
+		}
+	}
+}
+
+func TestOpenArchiveROM(t *testing.T) {
+	several := writeArchive(t, "readme.txt", "pong.ch8", "tetris.ch8")
+	empty := writeArchive(t, "sub/")
+	for _, tc := range []struct {
+		file string
+		name string
+		err  error
+	}{
+		{writeArchive(t, "readme.txt", "pong.ch8"), "pong.ch8", nil},
+		{several + ":tetris.ch8", "tetris.ch8", nil},
+		{several + ":readme.txt", "readme.txt", nil},
+		{several, "", ArchiveROMError{Archive: several, ROMs: []string{"pong.ch8", "tetris.ch8"}}},
+		{several + ":snake.ch8", "", NotInArchiveError{Archive: several, Name: "snake.ch8"}},
+		{empty, "", ArchiveROMError{Archive: empty}},
+	} {
+		archive, _ := SplitArchivePath(tc.file)
+		rf, err := OpenROM(tc.file)
+		if tc.err != nil {
+			if want := (ROMError{File: archive, Err: tc.err}); !reflect.DeepEqual(err, want) {
+				t.Errorf("%s: %v, want %v", tc.file, err, want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		if rf.Name != archive+":"+tc.name || !bytes.Equal(rf.Data, []byte(tc.name)) {
+			t.Errorf("%s: opened %s with %q, want %s", tc.file, rf.Name, rf.Data, tc.name)
+		}
+	}
+}
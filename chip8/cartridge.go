@@ -0,0 +1,145 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+
+	"github.com/MickLuypaerts/chip8Emu/assembler"
+)
+
+// Cartridge is an Octo cartridge, a gif that carries the source of a program and the settings it runs with.
+type Cartridge struct {
+	Source  string           `json:"program"`
+	Options CartridgeOptions `json:"options"`
+}
+
+// CartridgeOptions are the settings Octo saves in a cartridge, colors are #RRGGBB strings.
+type CartridgeOptions struct {
+	TickRate        int    `json:"tickrate"` // instructions per frame
+	MaxSize         int    `json:"maxSize"`  // 3216 for CHIP-8, 3583 for SUPER-CHIP and 65024 for XO-CHIP
+	ShiftQuirks     bool   `json:"shiftQuirks"`
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"`
+	ClipQuirks      bool   `json:"clipQuirks"`
+	JumpQuirks      bool   `json:"jumpQuirks"`
+	LogicQuirks     bool   `json:"logicQuirks"`
+	VBlankQuirks    bool   `json:"vBlankQuirks"`
+	BackgroundColor string `json:"backgroundColor"`
+	FillColor       string `json:"fillColor"`
+	FillColor2      string `json:"fillColor2"`
+	BlendColor      string `json:"blendColor"`
+}
+
+// DecodeCartridge reads the payload of an Octo cartridge. Every pixel of every frame holds two bits of it in the
+// low bits of its color index, most significant bits first, four pixels make a byte. The payload is a 32 bit big
+// endian length followed by that many bytes of JSON.
+func DecodeCartridge(r io.Reader) (*Cartridge, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var bits []byte
+	for _, frame := range g.Image {
+		bits = appendPixelBits(bits, frame)
+	}
+	octet := func(i int) byte {
+		var b byte
+		for _, p := range bits[i*4 : i*4+4] {
+			b = b<<2 | p
+		}
+		return b
+	}
+	if len(bits) < 16 {
+		return nil, InvalidCartridgeError{Reason: "the gif is too small"}
+	}
+	size := 0
+	for i := 0; i < 4; i++ {
+		size = size<<8 | int(octet(i))
+	}
+	if size > len(bits)/4-4 {
+		return nil, InvalidCartridgeError{Reason: "the payload is larger than the gif"}
+	}
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = octet(i + 4)
+	}
+	cart := new(Cartridge)
+	if err := json.Unmarshal(payload, cart); err != nil {
+		return nil, InvalidCartridgeError{Reason: err.Error()}
+	}
+	return cart, nil
+}
+
+// appendPixelBits appends the low two bits of the color index of every pixel of the frame, row by row.
+func appendPixelBits(bits []byte, frame *image.Paletted) []byte {
+	b := frame.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			bits = append(bits, frame.ColorIndexAt(x, y)&0x3)
+		}
+	}
+	return bits
+}
+
+// Assemble assembles the source of the cartridge.
+func (c *Cartridge) Assemble() (*assembler.Program, error) {
+	return assembler.Assemble(c.Source)
+}
+
+// Variant is the machine the cartridge was written for, judged by the memory it may use.
+func (o CartridgeOptions) Variant() Variant {
+	switch {
+	case o.MaxSize > 3583:
+		return VariantXOChip
+	case o.MaxSize > 3216:
+		return VariantSChip
+	}
+	return VariantChip8
+}
+
+// Quirks are the quirks the cartridge runs with.
+func (o CartridgeOptions) Quirks() Quirks {
+	q := Quirks{
+		VFReset:     o.LogicQuirks,
+		Shift:       o.ShiftQuirks,
+		Jump:        o.JumpQuirks,
+		Clip:        o.ClipQuirks,
+		LoadStore:   IndexIncrementX1,
+		DisplayWait: o.VBlankQuirks,
+	}
+	if o.LoadStoreQuirks {
+		q.LoadStore = IndexUnchanged
+	}
+	return q
+}
+
+// Speed is the clock speed in instructions per second, 0 when the cartridge does not set it.
+func (o CartridgeOptions) Speed() int {
+	return o.TickRate * 60
+}
+
+// Palette is the color of each bitplane combination, nil when the cartridge does not set every color.
+func (o CartridgeOptions) Palette() color.Palette {
+	p, err := ParsePalette([]string{o.BackgroundColor, o.FillColor, o.FillColor2, o.BlendColor})
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// readCartridge decodes a cartridge and assembles its program, sources the assembler does not support fail with a
+// CartridgeSourceError.
+func readCartridge(data []byte) (*Cartridge, *assembler.Program, error) {
+	cart, err := DecodeCartridge(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	prog, err := cart.Assemble()
+	if err != nil {
+		return nil, nil, CartridgeSourceError{Err: err}
+	}
+	return cart, prog, nil
+}
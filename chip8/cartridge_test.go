@@ -0,0 +1,98 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+// encodeCartridge writes a cartridge the way Octo does, two bits of the payload in the low bits of every pixel.
+func encodeCartridge(t *testing.T, cart Cartridge) []byte {
+	t.Helper()
+	data, err := json.Marshal(cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(data)
+	payload := append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, data...)
+	palette := color.Palette{color.Black, color.White, color.Gray{Y: 0x55}, color.Gray{Y: 0xAA}}
+	width := 64
+	img := image.NewPaletted(image.Rect(0, 0, width, (len(payload)*4+width-1)/width), palette)
+	for i, b := range payload {
+		for j := 0; j < 4; j++ {
+			p := i*4 + j
+			img.SetColorIndex(p%width, p/width, b>>(6-2*j)&0x3)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{img}, Delay: []int{0}}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeCartridge(t *testing.T) {
+	want := Cartridge{Source: ": main\n  v0 := 5\n  jump main\n", Options: CartridgeOptions{TickRate: 20, MaxSize: 3583, JumpQuirks: true}}
+	cart, err := DecodeCartridge(bytes.NewReader(encodeCartridge(t, want)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *cart != want {
+		t.Errorf("decoded %+v, want %+v", *cart, want)
+	}
+	if cart.Options.Variant() != VariantSChip || cart.Options.Speed() != 1200 || !cart.Options.Quirks().Jump {
+		t.Errorf("options run as %s at %d Hz with %s", cart.Options.Variant(), cart.Options.Speed(), cart.Options.Quirks())
+	}
+}
+
+// TestCartridgeSettings opens a cartridge that fails validation, a valid cartridge and a raw rom. Only the valid
+// cartridge changes the machine and the raw rom runs with the settings of the user again.
+func TestCartridgeSettings(t *testing.T) {
+	user := QuirksPresets["modern"]
+	c := newTestChip(t, user, loop...)
+	c.SetVariant(VariantChip8)
+	c.SetSpeed(700)
+	xo := CartridgeOptions{TickRate: 100, MaxSize: 65024, ShiftQuirks: true}
+
+	c.run()
+	empty := writeROM(t, "empty.gif", encodeCartridge(t, Cartridge{Source: "", Options: xo}))
+	if _, err := c.openROM(empty); err == nil {
+		t.Fatal("an empty cartridge was opened")
+	}
+	if !c.isRunning() {
+		t.Error("a cartridge that failed validation stopped the rom")
+	}
+	c.halt()
+	if c.variant != VariantChip8 || c.quirks != user || c.speed != 700 {
+		t.Errorf("a cartridge that failed validation left the machine as %s, %d Hz, quirks %s", c.variant, c.speed, c.quirks)
+	}
+
+	cart := writeROM(t, "cart.gif", encodeCartridge(t, Cartridge{Source: "clear", Options: xo}))
+	if _, err := c.openROM(cart); err != nil {
+		t.Fatal(err)
+	}
+	if c.variant != VariantXOChip || !c.quirks.Shift || c.speed != 6000 {
+		t.Errorf("the cartridge runs as %s, %d Hz, quirks %s", c.variant, c.speed, c.quirks)
+	}
+	if _, err := c.openROM(writeROM(t, "raw.ch8", loop)); err != nil {
+		t.Fatal(err)
+	}
+	if c.variant != VariantChip8 || c.quirks != user || c.speed != 700 {
+		t.Errorf("the rom after the cartridge runs as %s, %d Hz, quirks %s, want the settings of the user", c.variant, c.speed, c.quirks)
+	}
+}
+
+// TestCartridgeUnsupportedSource opens a cartridge using Octo macros, the error says the assembler only supports a
+// subset of Octo.
+func TestCartridgeUnsupportedSource(t *testing.T) {
+	src := ":macro twice X { X X }\n: main\n  twice clear\n"
+	_, err := OpenROM(writeROM(t, "macro.gif", encodeCartridge(t, Cartridge{Source: src})))
+	if !errors.As(err, new(CartridgeSourceError)) || !strings.Contains(err.Error(), ":macro") {
+		t.Errorf("%v, want an error naming the unsupported Octo features", err)
+	}
+}
@@ -1,176 +1,266 @@
 package chip8
 
 import (
-	"io/ioutil"
+	"fmt"
+	"image/color"
 	"time"
 
 	"github.com/MickLuypaerts/chip8Emu/emulator"
 )
 
 const (
-	memorySize            = 4096
-	vRegSize              = 16
-	stackSize             = 16
-	screenWidth           = 64
-	screenHeigth          = 32
-	keyNumbers            = 16
-	clockCycleRate        = 2 * time.Microsecond
-	timeCycleRate         = 16 * time.Microsecond
-	keyboardResetDuration = 50 * time.Millisecond
+	memorySize          = 4096
+	xoMemorySize        = 65536
+	vRegSize            = 16
+	stackSize           = 16
+	screenWidth         = 64
+	screenHeigth        = 32
+	hiresScreenWidth    = 128
+	hiresScreenHeigth   = 64
+	rplSize             = 16
+	schipRPLSize        = 8
+	audioPatternSize    = 16
+	keyNumbers          = 16
+	DefaultSpeed        = 700 // instructions per second
+	maxSpeed            = 1000000
+	timerHz             = 60
+	frameRate           = time.Second / timerHz
+	keyboardResetFrames = 3 // frames without a repeated key press before the keys are released
 )
 
-var (
-	keyboardInterrupt = make(chan byte, 1)
-	stopSignal        = make(chan struct{})
-	drawSignal        = make(chan []byte)
-	keySignal         = make(chan []byte, 1)
-	running           = false
-)
-
-type Chip8 struct {
+// cpu is the part of the machine that is small enough to be copied for every instruction the rewind buffer records,
+// memory and the screen are recorded as the bytes an instruction changes.
+type cpu struct {
 	opcode     uint16
+	opcodeAddr uint16 // address of the opcode, the INFO panel shows the instruction executed last
 	i          uint16 // The address register, which is named I, is 12 bits wide and is used with several opcodes that involve memory operations.
 	pc         uint16
 	stack      [stackSize]uint16
 	sp         byte
-	memory     [memorySize]byte
 	v          [vRegSize]byte // general purpose registers
-	vChanged   [vRegSize]bool
-	screenBuf  [screenWidth * screenHeigth]byte
-	drawFlag   bool
+	width      int
+	height     int
+	hires      bool
+	planes     byte // bitplanes selected by FN01, every other variant only draws on plane 1
 	key        [keyNumbers]byte
 	delayTimer byte
 	soundTimer byte
 
-	info       emulator.EmulatorInfo
-	SetEmuInfo func(emulator.ChipGetter)
+	waitingKey   bool // FX0A suspends execution until a key is pressed
+	waitRegister byte
+	waitHeld     bool // a key went down while waiting, FX0A completes on its release
+	waitKey      byte
+
+	rpl [rplSize]byte // SUPER-CHIP RPL user flags, FX75 and FX85
+
+	audioPattern [audioPatternSize]byte // XO-CHIP audio pattern buffer, F002
+	pitch        byte                   // XO-CHIP audio pattern playback pitch, FX3A
+
+	rng rng
+
+	cycle uint64 // instructions executed since the rom was loaded
+}
+
+type Chip8 struct {
+	cpu
+	memory    [xoMemorySize]byte
+	memSize   int
+	vChanged  [vRegSize]bool
+	screenBuf [hiresScreenWidth * hiresScreenHeigth]byte
+	drawFlag  bool
+
+	quirks  Quirks
+	variant Variant
+	exited  bool
+
+	keyboardInterrupt chan byte
+	stopSignal        chan struct{}
+	drawSignal        chan emulator.Frame
+	keySignal         chan []byte
+	exitSignal        chan struct{}
+	speedSignal       chan int
+	stopped           chan struct{} // closed when the frame loop returned after a stop
+	running           bool
+
+	speed       int // instructions per second
+	cycleDebt   int // speed * frames not yet executed as whole instructions, carries the remainder between frames
+	stepCycles  int // cycles stepped since the timers were last updated while stopped
+	keyHold     int // frames left before the pressed keys are released
+	spriteDrawn bool
+
+	file     string
+	loadAddr uint16
+	romHash  string // sha1 of the rom, save states of other roms are refused
+	history  history
+	debug    debugger
+	symbols  *Symbols
+	palette  color.Palette // colors of a cartridge or the rom database, nil for the colors of the TUI
+	defaults machineSettings
+
+	romdb         *ROMDatabase
+	romdbSettings DatabaseSetting
+	romInfo       *ROMInfo        // the rom in the database, nil when it is not in it
+	keyHints      map[string]byte // keys the rom database names for directions, "up": 5
+	keypad        KeypadLayout
+	trace         tracer
+	execAddr      uint16 // address of the instruction being executed
+	fault         error  // first error of the instruction being executed
+	onError       ErrorPolicy
+	SetEmuInfo    func(emulator.ChipGetter)
+	setMessage    func(string)
+}
+
+// machineSettings are the settings the user chose with the setters, the rom database and cartridges change them for
+// their roms and they are restored before the next rom is opened.
+type machineSettings struct {
+	variant  Variant
+	quirks   Quirks
+	speed    int
+	loadAddr uint16
+	palette  color.Palette
+}
+
+// New returns a machine with its own signals, several machines can run side by side in one process.
+func New() *Chip8 {
+	c := &Chip8{
+		keyboardInterrupt: make(chan byte, 1),
+		stopSignal:        make(chan struct{}),
+		drawSignal:        make(chan emulator.Frame),
+		keySignal:         make(chan []byte, 1),
+		exitSignal:        make(chan struct{}),
+		speedSignal:       make(chan int),
+		speed:             DefaultSpeed,
+		loadAddr:          DefaultLoadAddress,
+		defaults:          machineSettings{speed: DefaultSpeed, loadAddr: DefaultLoadAddress},
+	}
+	c.rng.seed(uint64(time.Now().UnixNano()))
+	c.history.resize(DefaultRewindSize, DefaultRewindMemory)
+	c.trace.to = 0xFFFF
+	return c
+}
+
+// SetSeed seeds the random number generator used by CXNN so runs can be repeated.
+func (c *Chip8) SetSeed(seed uint64) {
+	c.rng.seed(seed)
 }
 
 func (c *Chip8) Init(file string, tui emulator.TUISetter) error {
-	c.pc = 0x200 // programs written for the original system begin at memory location 512 (0x200)
 	c.SetEmuInfo = tui.SetEmuInfo
-	romData, err := ioutil.ReadFile(file)
+	c.setMessage = tui.SetMessage
+	rf, err := c.openROM(file)
 	if err != nil {
 		return err
 	}
-	// load fontset
-	fontset := [80]byte{
-		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-		0x20, 0x60, 0x20, 0x20, 0x70, // 1
-		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-		0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-		0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-		0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-		0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
-	}
-	for i := range fontset {
-		c.memory[i] = fontset[i]
-	}
-
-	// load program into memory
-	for i := range romData {
-		c.memory[i+512] = romData[i]
+	c.reset(rf.Name, rf.Data)
+	if rf.Labels != nil {
+		c.symbols = NewSymbols(rf.Labels)
 	}
 	return nil
 }
 
-func (c Chip8) KeySignal() <-chan []byte {
-	return keySignal
-}
-
-func (c Chip8) DrawSignal() <-chan []byte {
-	return drawSignal
+// Load replaces the rom with a rom file, a zip archive or an Octo cartridge and resets the machine, the rom is
+// stopped and the breakpoints are kept. The labels are replaced by the symbol file next to the rom or the labels
+// of the cartridge.
+func (c *Chip8) Load(file string) error {
+	rf, err := c.openROM(file)
+	if err != nil {
+		return err
+	}
+	if err := c.LoadROM(rf.Name, rf.Data); err != nil {
+		return err
+	}
+	if rf.Labels != nil {
+		c.paused(func() { c.symbols = NewSymbols(rf.Labels) })
+		return nil
+	}
+	return c.loadRomSymbols(file)
 }
 
-func (c *Chip8) fetch() {
-	c.opcode = uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+// LoadROM replaces the rom with one that is already in memory, name is shown as the rom name. The rom is stopped
+// and the breakpoints are kept.
+func (c *Chip8) LoadROM(name string, rom []byte) error {
+	if err := ValidateROM(rom, c.variant, c.loadAddr); err != nil {
+		return ROMError{File: name, Err: err}
+	}
+	c.halt()
+	c.reset(name, rom)
+	c.publishKeys()
+	c.refresh()
+	return nil
 }
 
-func (c *Chip8) updateTimers() {
-	if c.delayTimer > 0 {
-		c.delayTimer--
-	}
-	if c.soundTimer > 0 {
-		c.soundTimer--
+// reset puts the machine in its power on state with the rom loaded, the rom has been validated.
+func (c *Chip8) reset(file string, romData []byte) {
+	c.cpu = cpu{rng: c.rng}
+	c.memory = [xoMemorySize]byte{}
+	for i := range c.vChanged {
+		c.vChanged[i] = true
 	}
+	c.cycleDebt, c.stepCycles, c.keyHold = 0, 0, 0
+	c.debug.broken, c.debug.reason = false, ""
+	c.pc = c.loadAddr
+	c.file = file
+	c.romHash = HashROM(romData)
+	c.memSize = c.variant.MemorySize()
+	c.planes = 1
+	c.pitch = 64
+
+	copy(c.memory[fontAddr:], fontset[:])
+	copy(c.memory[bigFontAddr:], bigFontset[:])
+	c.setResolution(false)
+	c.history.clear()
+
+	copy(c.memory[c.loadAddr:c.memSize], romData)
 }
 
-func (c *Chip8) emulateCycle() {
-	c.fetch()
-	c.decode()
-	c.updateTimers()
-	if c.drawFlag {
-		drawSignal <- c.screenBuf[:]
-		c.drawFlag = false
-	}
-	c.SetEmuInfo(c)
+func (c *Chip8) KeySignal() <-chan []byte {
+	return c.keySignal
 }
 
-func (c *Chip8) run() {
-	stopSignal = make(chan struct{})
-	clock := time.NewTicker(clockCycleRate)
-	timers := time.NewTicker(timeCycleRate)
-	running = true
+func (c *Chip8) DrawSignal() <-chan emulator.Frame {
+	return c.drawSignal
+}
 
-	go c.runClockCycle(clock)
-	go c.runTimerCycle(timers)
+func (c *Chip8) ExitSignal() <-chan struct{} {
+	return c.exitSignal
 }
 
-func (c *Chip8) runClockCycle(clockTimer *time.Ticker) {
-	keyboardResetTimer := time.NewTimer(keyboardResetDuration)
-	for {
-		select {
-		case <-stopSignal:
-			clockTimer.Stop()
-			return
-		case <-clockTimer.C:
-			c.emulateCycle()
+// SetVariant sets the variant of the machine, roms of the rom database and cartridges can run as another variant.
+func (c *Chip8) SetVariant(v Variant) {
+	c.variant = v
+	c.defaults.variant = v
+}
 
-		case k := <-keyboardInterrupt:
-			if !keyboardResetTimer.Stop() {
-				select {
-				case <-keyboardResetTimer.C:
-				default:
-				}
-			}
-			keyboardResetTimer.Reset(keyboardResetDuration)
-			c.pressKey(k)
-		case <-keyboardResetTimer.C:
-			c.clearKeys()
-		}
-	}
+func (c *Chip8) fetch() {
+	c.opcode = uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
 }
 
-func (c *Chip8) runTimerCycle(timerTimer *time.Ticker) {
-	for {
-		select {
-		case <-stopSignal:
-			timerTimer.Stop()
-			return
-		case <-timerTimer.C:
-			c.updateTimers()
-		}
+func (c *Chip8) updateTimers() {
+	if c.delayTimer > 0 {
+		c.delayTimer--
+	}
+	if c.soundTimer > 0 {
+		c.soundTimer--
 	}
 }
 
-func (c *Chip8) stop() {
-	if running {
-		close(stopSignal)
-		running = false
+// exit halts the machine for good and tells the emulator to quit, SUPER-CHIP 00FD.
+func (c *Chip8) exit() {
+	if !c.exited {
+		c.exited = true
+		close(c.exitSignal)
 	}
 }
 
-func (c *Chip8) setEmulatorInfo(n string, t string, d string) {
-	c.info = emulator.CreateEmulatorInfo(c.opcode, n, t, d, c.pc)
+func (c *Chip8) setResolution(hires bool) {
+	c.hires = hires
+	if hires {
+		c.width, c.height = hiresScreenWidth, hiresScreenHeigth
+	} else {
+		c.width, c.height = screenWidth, screenHeigth
+	}
+	c.clearScreen()
+	c.drawFlag = true
 }
 
 func (c *Chip8) clearKeys() {
@@ -181,8 +271,11 @@ func (c *Chip8) clearKeys() {
 			clearedKey = true
 		}
 	}
+	if c.waitingKey && c.waitHeld {
+		c.endKeyWait(c.waitKey)
+	}
 	if clearedKey {
-		keySignal <- c.key[:]
+		c.publishKeys()
 	}
 }
 
@@ -194,5 +287,55 @@ func (c *Chip8) pressKey(key byte) {
 			}
 		}
 	}
-	keySignal <- c.key[:]
+	if c.waitingKey && !c.waitHeld {
+		if c.quirks.KeyRelease {
+			c.waitHeld = true
+			c.waitKey = key
+		} else {
+			c.endKeyWait(key)
+		}
+	}
+	c.publishKeys()
+}
+
+func (c *Chip8) publishKeys() {
+	keys := make([]byte, len(c.key))
+	copy(keys, c.key[:])
+	c.keySignal <- keys
+}
+
+func (c *Chip8) startKeyWait(x byte) {
+	c.waitingKey = true
+	c.waitRegister = x
+	c.waitHeld = false
+}
+
+func (c *Chip8) endKeyWait(key byte) {
+	c.v[c.waitRegister] = key
+	c.vChanged[c.waitRegister] = true
+	c.waitingKey = false
+	c.waitHeld = false
+}
+
+// SetQuirks sets the quirks of the machine, roms of the rom database and cartridges can run with other quirks.
+func (c *Chip8) SetQuirks(q Quirks) {
+	c.quirks = q
+	c.defaults.quirks = q
+}
+
+func (c *Chip8) status() string {
+	switch {
+	case c.waitingKey && c.waitHeld:
+		return fmt.Sprintf("waiting for release of key %X (V%X)", c.waitKey, c.waitRegister)
+	case c.waitingKey:
+		return fmt.Sprintf("waiting for key (V%X)", c.waitRegister)
+	case c.exited:
+		return "exited"
+	case c.debug.broken:
+		return "break: " + c.debug.reason
+	case c.running:
+		return "running"
+	default:
+		return "stopped"
+	}
 }
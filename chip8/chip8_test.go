@@ -0,0 +1,136 @@
+package chip8
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestChip returns a stopped machine with the rom loaded at 0x200, the frames and keys it publishes are drained.
+func newTestChip(t *testing.T, q Quirks, rom ...byte) *Chip8 {
+	t.Helper()
+	c := New()
+	c.SetSeed(1)
+	c.SetQuirks(q)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c.keySignal:
+			case <-c.drawSignal:
+			case <-done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		c.stop()
+		close(done)
+	})
+	c.reset("test.ch8", rom)
+	return c
+}
+
+// mustStep runs an instruction and fails the test when it failed.
+func mustStep(t *testing.T, c *Chip8) {
+	t.Helper()
+	if err := c.step(); err != nil {
+		t.Fatalf("step at %03X: %v", c.execAddr, err)
+	}
+}
+
+func TestWaitForKey(t *testing.T) {
+	for _, release := range []bool{false, true} {
+		c := newTestChip(t, Quirks{KeyRelease: release}, 0xF3, 0x0A, 0x65, 0x01)
+		mustStep(t, c)
+		if !c.waitingKey || c.pc != 0x202 {
+			t.Fatalf("keyrelease=%t: waiting=%t PC=%03X after F30A, want waiting at 202", release, c.waitingKey, c.pc)
+		}
+		if got, want := c.status(), "waiting for key (V3)"; got != want {
+			t.Errorf("keyrelease=%t: status %q, want %q", release, got, want)
+		}
+		mustStep(t, c)
+		if c.pc != 0x202 || c.cycle != 1 {
+			t.Errorf("keyrelease=%t: a step while waiting ran PC=%03X cycle=%d", release, c.pc, c.cycle)
+		}
+
+		c.pressKey(0xB)
+		if release {
+			if got, want := c.status(), "waiting for release of key B (V3)"; got != want {
+				t.Errorf("keyrelease=%t: status %q, want %q", release, got, want)
+			}
+			if c.v[3] != 0 {
+				t.Errorf("keyrelease=%t: V3=%X before the key was released", release, c.v[3])
+			}
+			mustStep(t, c)
+			if c.pc != 0x202 {
+				t.Errorf("keyrelease=%t: PC=%03X while the key is held", release, c.pc)
+			}
+			c.clearKeys()
+		}
+		if c.waitingKey || c.v[3] != 0xB {
+			t.Fatalf("keyrelease=%t: waiting=%t V3=%X, want V3=B", release, c.waitingKey, c.v[3])
+		}
+		if got, want := c.status(), "stopped"; got != want {
+			t.Errorf("keyrelease=%t: status %q, want %q", release, got, want)
+		}
+		mustStep(t, c)
+		if c.pc != 0x204 || c.v[5] != 1 {
+			t.Errorf("keyrelease=%t: PC=%03X V5=%d, want the rom to resume at 202", release, c.pc, c.v[5])
+		}
+	}
+}
+
+// TestStepWaitForKey presses a key the way the controls do while the rom is stopped, Step releases it again.
+func TestStepWaitForKey(t *testing.T) {
+	for _, release := range []bool{false, true} {
+		c := newTestChip(t, Quirks{KeyRelease: release}, 0xF3, 0x0A, 0x65, 0x01)
+		c.Step()
+		c.sendKeyboardInterrupt(0x4)
+		c.Step()
+		if c.waitingKey || c.v[3] != 0x4 || c.key[0x4] != 0 {
+			t.Fatalf("keyrelease=%t: waiting=%t V3=%X key 4=%d after the step, want V3=4 and the key released", release, c.waitingKey, c.v[3], c.key[0x4])
+		}
+		if release && c.pc != 0x202 {
+			t.Errorf("keyrelease=%t: PC=%03X, the step released the key and should not have run 6501", release, c.pc)
+		}
+		if !release && c.pc != 0x204 {
+			t.Errorf("keyrelease=%t: PC=%03X, the step should have run 6501", release, c.pc)
+		}
+	}
+}
+
+// TestSkipKeyMasksRegister checks that EX9E and EXA1 only look at the low nibble of VX like the COSMAC VIP.
+func TestSkipKeyMasksRegister(t *testing.T) {
+	for _, tc := range []struct {
+		op byte
+		pc uint16
+	}{
+		{0x9E, 0x206},
+		{0xA1, 0x204},
+	} {
+		c := newTestChip(t, Quirks{}, 0x60, 0xF5, 0xE0, tc.op)
+		c.pressKey(0x5)
+		mustStep(t, c)
+		mustStep(t, c)
+		if c.pc != tc.pc {
+			t.Errorf("E0%02X with V0=F5 and key 5 pressed: PC=%03X, want %03X", tc.op, c.pc, tc.pc)
+		}
+	}
+}
+
+// TestEmulatorInfo checks that the INFO panel shows the instruction executed last, also after stepping back.
+func TestEmulatorInfo(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x6A, 0x2A, 0xA3, 0x00, 0x12, 0x00)
+	if got := c.EmulatorInfo().String(); !strings.Contains(got, "PC: 512\n") || !strings.Contains(got, "OPCODE: 0x0000\n") {
+		t.Errorf("info of a rom that did not run:\n%s", got)
+	}
+	mustStep(t, c)
+	mustStep(t, c)
+	if got := c.EmulatorInfo().String(); !strings.Contains(got, "PC: 516\n") || !strings.Contains(got, "OPCODE: 0xA300\n") {
+		t.Errorf("info after A300:\n%s", got)
+	}
+	c.StepBack()
+	if got := c.EmulatorInfo().String(); !strings.Contains(got, "PC: 514\n") || !strings.Contains(got, "OPCODE: 0x6A2A\n") {
+		t.Errorf("info after stepping back to 6A2A:\n%s", got)
+	}
+}
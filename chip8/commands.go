@@ -0,0 +1,195 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+// CommandsMap returns the console commands of the machine, addresses and values are hexadecimal, addresses can
+// also be labels of the symbol file.
+func (c *Chip8) CommandsMap() map[string]emulator.Command {
+	m := make(map[string]emulator.Command)
+	m["break"] = emulator.NewCommand(c.breakCommand, "SPEC", "set a breakpoint: ADDR [if REG OP VALUE], read|write|access ADDR or op DXYN")
+	m["delete"] = emulator.NewCommand(c.deleteCommand, "SPEC", "remove a breakpoint")
+	m["breaks"] = emulator.NewCommand(c.breaksCommand, "", "list the breakpoints")
+	m["set"] = emulator.NewCommand(c.setCommand, "REG VALUE", "set v0-vf, i, pc, dt or st")
+	m["poke"] = emulator.NewCommand(c.pokeCommand, "ADDR BYTE...", "write bytes to memory")
+	m["goto"] = emulator.NewCommand(c.gotoCommand, "ADDR", "set the pc")
+	m["symbols"] = emulator.NewCommand(c.symbolsCommand, "FILE", "load the labels of a symbol file")
+	m["trace"] = emulator.NewCommand(c.traceCommand, "on|off|FILE [FORMAT]|range FROM TO", "trace the executed instructions to a file (text or binary)")
+	m["speed"] = emulator.NewCommand(c.speedCommand, "HZ", "set the clock speed in instructions per second (decimal)")
+	return m
+}
+
+func (c *Chip8) breakCommand(args []string) (string, error) {
+	spec := strings.Join(args, " ")
+	if err := c.AddBreak(spec); err != nil {
+		return "", err
+	}
+	c.refresh()
+	return "break " + spec, nil
+}
+
+func (c *Chip8) deleteCommand(args []string) (string, error) {
+	spec := strings.Join(args, " ")
+	if err := c.RemoveBreak(spec); err != nil {
+		return "", err
+	}
+	c.refresh()
+	return "deleted " + spec, nil
+}
+
+func (c *Chip8) breaksCommand(args []string) (string, error) {
+	breaks := c.Breaks()
+	if len(breaks) == 0 {
+		return "no breakpoints", nil
+	}
+	return strings.Join(breaks, "\n"), nil
+}
+
+func (c *Chip8) setCommand(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", emulator.CommandArgsError{Name: "set", Args: "REG VALUE"}
+	}
+	value, err := c.parseAddr(args[1])
+	if err != nil {
+		return "", err
+	}
+	reg := strings.ToLower(args[0])
+	var set func()
+	switch reg {
+	case "i":
+		set = func() { c.i = value }
+	case "pc":
+		if int(value)+2 > c.memSize {
+			return "", MemoryBoundsError{Addr: int(value) + 1, Size: c.memSize}
+		}
+		set = func() { c.pc = value }
+	case "dt", "st":
+		if value > 0xFF {
+			return "", InvalidByteError{Value: args[1]}
+		}
+		timer := &c.delayTimer
+		if reg == "st" {
+			timer = &c.soundTimer
+		}
+		set = func() { *timer = byte(value) }
+	default:
+		n, err := strconv.ParseUint(strings.TrimPrefix(reg, "v"), 16, 8)
+		if !strings.HasPrefix(reg, "v") || err != nil || n >= vRegSize {
+			return "", UnknownRegisterError{Name: args[0]}
+		}
+		if value > 0xFF {
+			return "", InvalidByteError{Value: args[1]}
+		}
+		set = func() {
+			c.v[n] = byte(value)
+			c.vChanged[n] = true
+		}
+	}
+	c.paused(set)
+	c.refresh()
+	return fmt.Sprintf("%s = 0x%X", reg, value), nil
+}
+
+func (c *Chip8) pokeCommand(args []string) (string, error) {
+	if len(args) < 2 {
+		return "", emulator.CommandArgsError{Name: "poke", Args: "ADDR BYTE..."}
+	}
+	addr, err := c.parseAddr(args[0])
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, len(args)-1)
+	for i, arg := range args[1:] {
+		b, err := parseHex(arg)
+		if err != nil || b > 0xFF {
+			return "", InvalidByteError{Value: arg}
+		}
+		data[i] = byte(b)
+	}
+	if int(addr)+len(data) > c.memSize {
+		return "", MemoryBoundsError{Addr: int(addr) + len(data) - 1, Size: c.memSize}
+	}
+	c.paused(func() { copy(c.memory[addr:], data) })
+	c.refresh()
+	return fmt.Sprintf("wrote %d bytes at 0x%03X", len(data), addr), nil
+}
+
+func (c *Chip8) gotoCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", emulator.CommandArgsError{Name: "goto", Args: "ADDR"}
+	}
+	return c.setCommand([]string{"pc", args[0]})
+}
+
+func (c *Chip8) speedCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", emulator.CommandArgsError{Name: "speed", Args: "HZ"}
+	}
+	ips, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "", err
+	}
+	c.paused(func() { c.SetSpeed(ips) })
+	c.refresh()
+	return fmt.Sprintf("speed %d Hz", c.speed), nil
+}
+
+func (c *Chip8) symbolsCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", emulator.CommandArgsError{Name: "symbols", Args: "FILE"}
+	}
+	if err := c.LoadSymbols(args[0]); err != nil {
+		return "", err
+	}
+	c.refresh()
+	return fmt.Sprintf("loaded %d labels", len(c.symbols.addrs)), nil
+}
+
+func (c *Chip8) traceCommand(args []string) (string, error) {
+	usage := emulator.CommandArgsError{Name: "trace", Args: "on|off|FILE [FORMAT]|range FROM TO"}
+	if len(args) == 0 {
+		return "", usage
+	}
+	switch args[0] {
+	case "off":
+		if err := c.StopTrace(); err != nil {
+			return "", err
+		}
+		return "stopped tracing", nil
+	case "on":
+		if active, _ := c.Tracing(); !active {
+			c.ToggleTrace()
+		}
+		_, file := c.Tracing()
+		return "tracing to " + file, nil
+	case "range":
+		if len(args) != 3 {
+			return "", usage
+		}
+		from, to, err := ParseTraceRange(args[1] + "-" + args[2])
+		if err != nil {
+			return "", err
+		}
+		c.SetTraceRange(from, to)
+		return fmt.Sprintf("tracing 0x%03X-0x%03X", from, to), nil
+	}
+	format := c.trace.format
+	if len(args) > 2 {
+		return "", usage
+	}
+	if len(args) == 2 {
+		var err error
+		if format, err = ParseTraceFormat(args[1]); err != nil {
+			return "", err
+		}
+	}
+	if err := c.StartTrace(args[0], format); err != nil {
+		return "", err
+	}
+	return "tracing to " + args[0], nil
+}
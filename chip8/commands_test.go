@@ -0,0 +1,177 @@
+package chip8
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+func TestSetCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	for _, args := range [][]string{{"v3", "2A"}, {"VF", "0xff"}, {"i", "FFFF"}, {"dt", "3C"}, {"st", "1"}, {"pc", "FFE"}} {
+		if _, err := c.setCommand(args); err != nil {
+			t.Errorf("set %q: %v", args, err)
+		}
+	}
+	if c.v[3] != 0x2A || c.v[0xF] != 0xFF || c.i != 0xFFFF || c.delayTimer != 0x3C || c.soundTimer != 1 || c.pc != 0xFFE {
+		t.Errorf("V3=%X VF=%X I=%X DT=%X ST=%X PC=%X after the sets", c.v[3], c.v[0xF], c.i, c.delayTimer, c.soundTimer, c.pc)
+	}
+	for _, tc := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"v3"}, emulator.CommandArgsError{}},
+		{[]string{"vg", "1"}, UnknownRegisterError{}},
+		{[]string{"v10", "1"}, UnknownRegisterError{}},
+		{[]string{"sp", "1"}, UnknownRegisterError{}},
+		{[]string{"v0", "100"}, InvalidByteError{}},
+		{[]string{"dt", "100"}, InvalidByteError{}},
+		{[]string{"pc", "FFF"}, MemoryBoundsError{}},
+		{[]string{"i", "10000"}, nil},
+		{[]string{"v0", "xyz"}, nil},
+	} {
+		if err := commandError(c.setCommand(tc.args)); !isError(err, tc.err) {
+			t.Errorf("set %q: %v, want a %T", tc.args, err, tc.err)
+		}
+	}
+}
+
+func TestPokeCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	if _, err := c.pokeCommand([]string{"FFE", "1", "ff"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.memory[0xFFE] != 1 || c.memory[0xFFF] != 0xFF {
+		t.Errorf("wrote %02X %02X, want 01 FF", c.memory[0xFFE], c.memory[0xFFF])
+	}
+	for _, tc := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"300"}, emulator.CommandArgsError{}},
+		{[]string{"1000", "1"}, MemoryBoundsError{}},
+		{[]string{"FFF", "1", "2"}, MemoryBoundsError{}},
+		{[]string{"300", "100"}, InvalidByteError{}},
+		{[]string{"300", "1", "zz"}, InvalidByteError{}},
+	} {
+		if err := commandError(c.pokeCommand(tc.args)); !isError(err, tc.err) {
+			t.Errorf("poke %q: %v, want a %T", tc.args, err, tc.err)
+		}
+	}
+	if c.memory[0x300] != 0 {
+		t.Errorf("a failed poke wrote %02X at 0x300", c.memory[0x300])
+	}
+}
+
+func TestGotoCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	if _, err := c.gotoCommand([]string{"2A4"}); err != nil || c.pc != 0x2A4 {
+		t.Errorf("goto 2A4: PC=%03X %v", c.pc, err)
+	}
+	for _, tc := range []struct {
+		args []string
+		err  error
+	}{
+		{nil, emulator.CommandArgsError{}},
+		{[]string{"200", "202"}, emulator.CommandArgsError{}},
+		{[]string{"1000"}, MemoryBoundsError{}},
+		{[]string{"main"}, nil},
+	} {
+		if err := commandError(c.gotoCommand(tc.args)); !isError(err, tc.err) {
+			t.Errorf("goto %q: %v, want a %T", tc.args, err, tc.err)
+		}
+	}
+	if c.pc != 0x2A4 {
+		t.Errorf("a failed goto moved the PC to %03X", c.pc)
+	}
+}
+
+func TestBreakCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	for _, args := range [][]string{{"2A4", "if", "v3", "==", "10"}, {"write", "300"}} {
+		if _, err := c.breakCommand(args); err != nil {
+			t.Errorf("break %q: %v", args, err)
+		}
+	}
+	for _, args := range [][]string{nil, {"main"}, {"2A4", "if", "v3", "=", "1"}, {"op", "DXYZ"}} {
+		if _, err := c.breakCommand(args); err == nil {
+			t.Errorf("break %q was added", args)
+		}
+	}
+	if got := c.Breaks(); len(got) != 2 {
+		t.Errorf("breaks %q, want the two valid ones", got)
+	}
+}
+
+func TestTraceCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	if _, err := c.traceCommand([]string{"range", "206", "209"}); err != nil || c.trace.from != 0x206 || c.trace.to != 0x209 {
+		t.Errorf("trace range 206 209: %03X-%03X %v", c.trace.from, c.trace.to, err)
+	}
+	for _, tc := range []struct {
+		args []string
+		err  error
+	}{
+		{nil, emulator.CommandArgsError{}},
+		{[]string{"range", "206"}, emulator.CommandArgsError{}},
+		{[]string{"range", "209", "206"}, nil},
+		{[]string{"range", "xyz", "209"}, nil},
+		{[]string{"out.trace", "binary", "now"}, emulator.CommandArgsError{}},
+		{[]string{"out.trace", "json"}, UnknownTraceFormatError{}},
+	} {
+		if err := commandError(c.traceCommand(tc.args)); !isError(err, tc.err) {
+			t.Errorf("trace %q: %v, want a %T", tc.args, err, tc.err)
+		}
+	}
+	if active, _ := c.Tracing(); active {
+		t.Error("a failed trace command started tracing")
+	}
+	file := filepath.Join(t.TempDir(), "out.trace")
+	if _, err := c.traceCommand([]string{file, "binary"}); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := c.Tracing(); !active || c.trace.format != TraceBinary {
+		t.Errorf("tracing %t in %s, want a binary trace", active, c.trace.format)
+	}
+	if _, err := c.traceCommand([]string{"off"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpeedCommand(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	for _, tc := range []struct {
+		arg  string
+		want int
+	}{
+		{"1000", 1000},
+		{"0", 1},
+		{"-5", 1},
+		{"100000000", maxSpeed},
+	} {
+		if _, err := c.speedCommand([]string{tc.arg}); err != nil || c.Speed() != tc.want {
+			t.Errorf("speed %s: %d Hz %v, want %d Hz", tc.arg, c.Speed(), err, tc.want)
+		}
+	}
+	for _, args := range [][]string{nil, {"fast"}, {"1000", "2000"}, {"1e3"}} {
+		if _, err := c.speedCommand(args); err == nil {
+			t.Errorf("speed %q was set", args)
+		}
+	}
+	if c.Speed() != maxSpeed {
+		t.Errorf("a failed speed command changed the speed to %d Hz", c.Speed())
+	}
+}
+
+// commandError returns the error of a command.
+func commandError(_ string, err error) error {
+	return err
+}
+
+// isError reports whether err is an error of the type of target, any error matches a nil target.
+func isError(err, target error) bool {
+	return err != nil && (target == nil || errors.As(err, reflect.New(reflect.TypeOf(target)).Interface()))
+}
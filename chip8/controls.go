@@ -2,33 +2,39 @@ package chip8
 
 import "github.com/MickLuypaerts/chip8Emu/emulator"
 
-func (c Chip8) ControlsMap() map[string]emulator.Control {
+func (c *Chip8) ControlsMap() map[string]emulator.Control {
 	m := make(map[string]emulator.Control)
-	m["0"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x0) }, "")
-	m["1"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x1) }, "")
-	m["2"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x2) }, "")
-	m["3"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x3) }, "")
-	m["4"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x4) }, "")
-	m["5"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x5) }, "")
-	m["6"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x6) }, "")
-	m["7"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x7) }, "")
-	m["8"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x8) }, "")
-	m["9"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0x9) }, "")
-	m["a"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xA) }, "")
-	m["b"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xB) }, "")
-	m["c"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xC) }, "")
-	m["d"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xD) }, "")
-	m["e"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xE) }, "")
-	m["f"] = emulator.NewControl(func() { sendKeyboardInterrupt(keyboardInterrupt, 0xF) }, "")
+	c.keypadControls(m)
 
-	m["r"] = emulator.NewControl(c.run, "run rom")
-	m["R"] = emulator.NewControl(c.stop, "stop rom")
-	m["s"] = emulator.NewControl(c.emulateCycle, "run 1 cycle")
+	m["<F9>"] = emulator.NewControl(c.run, "run rom")
+	m["R"] = emulator.NewControl(c.halt, "stop rom")
+	m["<F10>"] = emulator.NewControl(c.Step, "run 1 cycle")
+	m["S"] = emulator.NewControl(c.StepFrame, "run 1 frame")
+	m["n"] = emulator.NewControl(c.StepOver, "step over call")
+	m["o"] = emulator.NewControl(c.StepOut, "step out of subroutine")
+	m["<Backspace>"] = emulator.NewControl(c.StepBack, "step back 1 cycle")
+	m["<Delete>"] = emulator.NewControl(c.rewind, "rewind 1 frame, hold to run backwards")
+	m["<Left>"] = emulator.NewControl(func() { c.pressKeyHint("left") }, "left key of roms in the rom database")
+	m["<Right>"] = emulator.NewControl(func() { c.pressKeyHint("right") }, "right key of roms in the rom database")
+	m["<Up>"] = emulator.NewControl(func() { c.pressKeyHint("up") }, "up key of roms in the rom database")
+	m["<Down>"] = emulator.NewControl(func() { c.pressKeyHint("down") }, "down key of roms in the rom database")
+	m["p"] = emulator.NewControl(c.togglePause, "pause/resume rom")
+	m["t"] = emulator.NewControl(c.ToggleTrace, "start/stop tracing instructions")
+	m[">"] = emulator.NewControl(c.speedUp, "increase speed")
+	m["<"] = emulator.NewControl(c.speedDown, "decrease speed")
 	return m
 }
 
-func sendKeyboardInterrupt(c chan byte, key byte) {
-	if running {
-		c <- key
+// sendKeyboardInterrupt hands the key to the running clock cycle, when the rom is stopped the key is pressed
+// directly so stepping through FX0A and EX9E/EXA1 works.
+func (c *Chip8) sendKeyboardInterrupt(key byte) {
+	if !c.isRunning() {
+		c.pressKey(key)
+		return
+	}
+	select {
+	case c.keyboardInterrupt <- key:
+	case <-c.stopped:
+		c.pressKey(key)
 	}
 }
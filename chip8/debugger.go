@@ -0,0 +1,392 @@
+package chip8
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Watch selects the memory accesses a watchpoint breaks on.
+type Watch int
+
+const (
+	WatchRead Watch = 1 << iota
+	WatchWrite
+	WatchAccess = WatchRead | WatchWrite
+)
+
+var watchNames = map[string]Watch{
+	"read":   WatchRead,
+	"write":  WatchWrite,
+	"access": WatchAccess,
+}
+
+const regI = vRegSize // register number of I in conditions
+
+// Condition compares a register with a value, registers 0-F are V0-VF and 16 is I.
+type Condition struct {
+	Reg   int
+	Op    string
+	Value uint16
+}
+
+var conditionRegexp = regexp.MustCompile(`^(?i)\s*(v[0-9a-f]|i)\s*(==|!=|<=|>=|<|>)\s*(\S+)\s*$`)
+
+// ParseCondition parses conditions like v3 == 10 or i >= 300, values are hexadecimal.
+func ParseCondition(s string) (Condition, error) {
+	m := conditionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Condition{}, InvalidConditionError{Condition: s}
+	}
+	cond := Condition{Reg: regI, Op: m[2]}
+	if r := strings.ToLower(m[1]); r != "i" {
+		reg, _ := strconv.ParseUint(r[1:], 16, 8)
+		cond.Reg = int(reg)
+	}
+	value, err := parseHex(m[3])
+	if err != nil {
+		return Condition{}, InvalidConditionError{Condition: s}
+	}
+	cond.Value = value
+	return cond, nil
+}
+
+func (cond Condition) holds(c *Chip8) bool {
+	r := c.i
+	if cond.Reg < regI {
+		r = uint16(c.v[cond.Reg])
+	}
+	switch cond.Op {
+	case "==":
+		return r == cond.Value
+	case "!=":
+		return r != cond.Value
+	case "<":
+		return r < cond.Value
+	case ">":
+		return r > cond.Value
+	case "<=":
+		return r <= cond.Value
+	case ">=":
+		return r >= cond.Value
+	}
+	return false
+}
+
+func (cond Condition) String() string {
+	reg := "i"
+	if cond.Reg < regI {
+		reg = fmt.Sprintf("v%X", cond.Reg)
+	}
+	return fmt.Sprintf("%s %s 0x%X", reg, cond.Op, cond.Value)
+}
+
+// opcodePattern matches opcodes like DXYN, the letters X, Y and N are wildcards for a nibble.
+type opcodePattern struct {
+	mask  uint16
+	value uint16
+	text  string
+}
+
+func parseOpcodePattern(s string) (opcodePattern, error) {
+	s = strings.ToUpper(s)
+	if len(s) != 4 {
+		return opcodePattern{}, InvalidBreakpointError{Spec: "op " + s}
+	}
+	p := opcodePattern{text: s}
+	for _, r := range s {
+		p.mask <<= 4
+		p.value <<= 4
+		switch {
+		case r == 'X' || r == 'Y' || r == 'N':
+		case strings.ContainsRune("0123456789ABCDEF", r):
+			n, _ := strconv.ParseUint(string(r), 16, 8)
+			p.mask |= 0xF
+			p.value |= uint16(n)
+		default:
+			return opcodePattern{}, InvalidBreakpointError{Spec: "op " + s}
+		}
+	}
+	return p, nil
+}
+
+func (p opcodePattern) matches(opcode uint16) bool {
+	return opcode&p.mask == p.value
+}
+
+// debugger holds the breakpoints of the machine, they are only changed while the rom is stopped.
+type debugger struct {
+	breakpoints map[uint16]*Condition // a breakpoint without condition always breaks
+	watchpoints map[uint16]Watch
+	opcodes     []opcodePattern
+
+	until       func(c *Chip8) bool // stops stepping over a call and stepping out of a subroutine
+	untilReason string
+
+	resume bool   // the instruction the rom resumes on does not break again
+	hit    string // reason of a watchpoint hit by the instruction being executed
+	broken bool
+	reason string // why the rom stopped, shown in the INFO panel
+}
+
+// before returns why the rom breaks before the instruction at the PC is executed.
+func (d *debugger) before(c *Chip8) string {
+	if d.resume {
+		d.resume = false
+		return ""
+	}
+	if cond, ok := d.breakpoints[c.pc]; ok {
+		if cond == nil {
+			return fmt.Sprintf("breakpoint %s", c.addrName(c.pc))
+		}
+		if cond.holds(c) {
+			return fmt.Sprintf("breakpoint %s if %s", c.addrName(c.pc), cond)
+		}
+	}
+	if len(d.opcodes) > 0 {
+		opcode := wordAt(c.memory[:], int(c.pc))
+		for _, p := range d.opcodes {
+			if p.matches(opcode) {
+				return fmt.Sprintf("opcode %s (0x%04X) at 0x%03X", p.text, opcode, c.pc)
+			}
+		}
+	}
+	return ""
+}
+
+// after returns why the rom breaks after an instruction was executed.
+func (d *debugger) after(c *Chip8) string {
+	if d.hit != "" {
+		hit := d.hit
+		d.hit = ""
+		return hit
+	}
+	if d.until != nil && d.until(c) {
+		return d.untilReason
+	}
+	return ""
+}
+
+// access records a hit when a watchpoint of the kind is set on addr.
+func (d *debugger) access(addr uint16, kind Watch) {
+	if len(d.watchpoints) == 0 || d.hit != "" {
+		return
+	}
+	if d.watchpoints[addr]&kind != 0 {
+		name := "read"
+		if kind == WatchWrite {
+			name = "write"
+		}
+		d.hit = fmt.Sprintf("watchpoint %s 0x%03X", name, addr)
+	}
+}
+
+// continueExecution clears the last break, the instruction at the PC is executed even when it has a breakpoint.
+func (d *debugger) continueExecution() {
+	d.resume = true
+	d.broken = false
+	d.reason = ""
+}
+
+func (c *Chip8) breakExecution(reason string) {
+	c.debug.broken = true
+	c.debug.reason = reason
+	c.debug.until = nil
+}
+
+// read returns the byte at addr for instructions reading data, watchpoints are checked. Reading past the memory
+// fails the instruction.
+func (c *Chip8) read(addr uint16) byte {
+	if int(addr) >= c.memSize {
+		c.fail(MemoryAccessError{PC: c.execAddr, Addr: addr, Size: c.memSize})
+		return 0
+	}
+	c.debug.access(addr, WatchRead)
+	return c.memory[addr]
+}
+
+// breakSpec is a parsed breakpoint: an address with an optional condition, a watchpoint or an opcode pattern.
+type breakSpec struct {
+	addr    uint16
+	cond    *Condition
+	watch   Watch
+	opcode  *opcodePattern
+	isWatch bool
+}
+
+// parseBreak parses the breakpoint specs AddBreak and RemoveBreak take:
+//
+//	2A4              break before the instruction at 0x2A4
+//	2A4 if v3 == 10  break at 0x2A4 when V3 is 0x10, registers v0-vf and i can be compared with == != < > <= >=
+//	write 300        break after an instruction wrote 0x300, read and access (both) work the same
+//	op DXYN          break before any instruction matching the pattern, X, Y and N match any nibble
+//
+// Addresses and values are hexadecimal with an optional 0x prefix, addresses can also be labels of the symbol file.
+func (c *Chip8) parseBreak(spec string) (breakSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return breakSpec{}, InvalidBreakpointError{Spec: spec}
+	}
+	if watch, ok := watchNames[strings.ToLower(fields[0])]; ok {
+		if len(fields) != 2 {
+			return breakSpec{}, InvalidBreakpointError{Spec: spec}
+		}
+		addr, err := c.parseAddr(fields[1])
+		if err != nil {
+			return breakSpec{}, InvalidBreakpointError{Spec: spec}
+		}
+		return breakSpec{addr: addr, watch: watch, isWatch: true}, nil
+	}
+	if strings.EqualFold(fields[0], "op") {
+		if len(fields) != 2 {
+			return breakSpec{}, InvalidBreakpointError{Spec: spec}
+		}
+		p, err := parseOpcodePattern(fields[1])
+		if err != nil {
+			return breakSpec{}, err
+		}
+		return breakSpec{opcode: &p}, nil
+	}
+	addr, err := c.parseAddr(fields[0])
+	if err != nil {
+		return breakSpec{}, InvalidBreakpointError{Spec: spec}
+	}
+	s := breakSpec{addr: addr}
+	if len(fields) > 1 {
+		if !strings.EqualFold(fields[1], "if") {
+			return breakSpec{}, InvalidBreakpointError{Spec: spec}
+		}
+		cond, err := ParseCondition(strings.Join(fields[2:], " "))
+		if err != nil {
+			return breakSpec{}, err
+		}
+		s.cond = &cond
+	}
+	return s, nil
+}
+
+func parseHex(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "0x")
+	n, err := strconv.ParseUint(s, 16, 16)
+	return uint16(n), err
+}
+
+// AddBreak sets the breakpoint, watchpoint or opcode break of the spec, see parseBreak for the syntax.
+func (c *Chip8) AddBreak(spec string) error {
+	s, err := c.parseBreak(spec)
+	if err != nil {
+		return err
+	}
+	c.paused(func() {
+		switch {
+		case s.isWatch:
+			if c.debug.watchpoints == nil {
+				c.debug.watchpoints = make(map[uint16]Watch)
+			}
+			c.debug.watchpoints[s.addr] |= s.watch
+		case s.opcode != nil:
+			c.debug.opcodes = append(c.debug.opcodes, *s.opcode)
+		default:
+			c.setBreakpoint(s.addr, s.cond)
+		}
+	})
+	return nil
+}
+
+// RemoveBreak removes what AddBreak set for the spec, the condition of a breakpoint is ignored.
+func (c *Chip8) RemoveBreak(spec string) error {
+	s, err := c.parseBreak(spec)
+	if err != nil {
+		return err
+	}
+	c.paused(func() {
+		switch {
+		case s.isWatch:
+			c.debug.watchpoints[s.addr] &^= s.watch
+			if c.debug.watchpoints[s.addr] == 0 {
+				delete(c.debug.watchpoints, s.addr)
+			}
+		case s.opcode != nil:
+			opcodes := c.debug.opcodes[:0]
+			for _, p := range c.debug.opcodes {
+				if p.text != s.opcode.text {
+					opcodes = append(opcodes, p)
+				}
+			}
+			c.debug.opcodes = opcodes
+		default:
+			delete(c.debug.breakpoints, s.addr)
+		}
+	})
+	return nil
+}
+
+func (c *Chip8) setBreakpoint(addr uint16, cond *Condition) {
+	if c.debug.breakpoints == nil {
+		c.debug.breakpoints = make(map[uint16]*Condition)
+	}
+	c.debug.breakpoints[addr] = cond
+}
+
+// ToggleBreakpoint sets or removes an unconditional breakpoint at addr.
+func (c *Chip8) ToggleBreakpoint(addr uint16) {
+	c.paused(func() {
+		if _, ok := c.debug.breakpoints[addr]; ok {
+			delete(c.debug.breakpoints, addr)
+		} else {
+			c.setBreakpoint(addr, nil)
+		}
+	})
+}
+
+// Breaks returns the specs of all breakpoints, watchpoints and opcode breaks.
+func (c *Chip8) Breaks() []string {
+	var specs []string
+	for addr, cond := range c.debug.breakpoints {
+		if cond == nil {
+			specs = append(specs, c.addrName(addr))
+		} else {
+			specs = append(specs, fmt.Sprintf("%s if %s", c.addrName(addr), cond))
+		}
+	}
+	for addr, watch := range c.debug.watchpoints {
+		for name, w := range watchNames {
+			if w == watch {
+				specs = append(specs, fmt.Sprintf("%s %s", name, c.addrName(addr)))
+			}
+		}
+	}
+	for _, p := range c.debug.opcodes {
+		specs = append(specs, "op "+p.text)
+	}
+	sort.Strings(specs)
+	return specs
+}
+
+// StepOver runs a 2NNN call until it returned, any other instruction is stepped.
+func (c *Chip8) StepOver() {
+	if c.isRunning() {
+		return
+	}
+	if c.variant.opcodeName(wordAt(c.memory[:], int(c.pc))) != "2NNN" {
+		c.Step()
+		return
+	}
+	ret, sp := c.pc+2, c.sp
+	c.debug.until = func(c *Chip8) bool { return c.pc == ret && c.sp == sp }
+	c.debug.untilReason = "stepped over call"
+	c.run()
+}
+
+// StepOut runs until the current subroutine returned with 00EE.
+func (c *Chip8) StepOut() {
+	if c.isRunning() || c.sp == 0 {
+		return
+	}
+	sp := c.sp
+	c.debug.until = func(c *Chip8) bool { return c.sp < sp }
+	c.debug.untilReason = "stepped out of subroutine"
+	c.run()
+}
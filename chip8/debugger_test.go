@@ -0,0 +1,179 @@
+package chip8
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		cond Condition
+	}{
+		{"v3 == 10", Condition{Reg: 3, Op: "==", Value: 0x10}},
+		{"VF!=0x1", Condition{Reg: 0xF, Op: "!=", Value: 1}},
+		{"i >= 300", Condition{Reg: regI, Op: ">=", Value: 0x300}},
+		{" I<FFFF ", Condition{Reg: regI, Op: "<", Value: 0xFFFF}},
+		{"va <= ff", Condition{Reg: 0xA, Op: "<=", Value: 0xFF}},
+	} {
+		cond, err := ParseCondition(tc.s)
+		if err != nil {
+			t.Errorf("%q: %v", tc.s, err)
+			continue
+		}
+		if cond != tc.cond {
+			t.Errorf("%q parsed as %s, want %s", tc.s, cond, tc.cond)
+		}
+	}
+	for _, s := range []string{"", "v3 = 1", "vg == 1", "v10 == 1", "i == xyz", "i == 10000", "pc == 200", "v3 == 1 2"} {
+		if _, err := ParseCondition(s); err == nil {
+			t.Errorf("%q was parsed", s)
+		}
+	}
+}
+
+func TestParseBreak(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	for _, tc := range []struct {
+		spec string
+		want breakSpec
+	}{
+		{"2A4", breakSpec{addr: 0x2A4}},
+		{"0x2a4 if v3 == 10", breakSpec{addr: 0x2A4, cond: &Condition{Reg: 3, Op: "==", Value: 0x10}}},
+		{"2A4 IF i > 300", breakSpec{addr: 0x2A4, cond: &Condition{Reg: regI, Op: ">", Value: 0x300}}},
+		{"write 300", breakSpec{addr: 0x300, watch: WatchWrite, isWatch: true}},
+		{"Read 0x300", breakSpec{addr: 0x300, watch: WatchRead, isWatch: true}},
+		{"access FFF", breakSpec{addr: 0xFFF, watch: WatchAccess, isWatch: true}},
+		{"op dxyn", breakSpec{opcode: &opcodePattern{mask: 0xF000, value: 0xD000, text: "DXYN"}}},
+		{"op 00E0", breakSpec{opcode: &opcodePattern{mask: 0xFFFF, value: 0x00E0, text: "00E0"}}},
+	} {
+		s, err := c.parseBreak(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if s.addr != tc.want.addr || s.watch != tc.want.watch || s.isWatch != tc.want.isWatch ||
+			(s.cond == nil) != (tc.want.cond == nil) || s.cond != nil && *s.cond != *tc.want.cond ||
+			(s.opcode == nil) != (tc.want.opcode == nil) || s.opcode != nil && *s.opcode != *tc.want.opcode {
+			t.Errorf("%q parsed as %+v, want %+v", tc.spec, s, tc.want)
+		}
+	}
+	for _, spec := range []string{"", "main", "2A4 when v3 == 1", "2A4 if", "2A4 if v3 = 1", "write", "write 300 301",
+		"read xyz", "op", "op DXY", "op DXYZ", "op DXYN 1"} {
+		if _, err := c.parseBreak(spec); err == nil {
+			t.Errorf("%q was parsed", spec)
+		}
+	}
+}
+
+// waitForBreak waits for the frame loop to stop at a break.
+func waitForBreak(t *testing.T, c *Chip8) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.isRunning() {
+		if time.Now().After(deadline) {
+			c.halt()
+			t.Fatal("the frame loop did not stop")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.debug.broken {
+		t.Fatal("the frame loop stopped without a break")
+	}
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	// v0 += 1 in a loop
+	c := newTestChip(t, Quirks{}, 0x70, 0x01, 0x12, 0x00)
+	if err := c.AddBreak("200 if v0 == A"); err != nil {
+		t.Fatal(err)
+	}
+	c.run()
+	waitForBreak(t, c)
+	if c.pc != 0x200 || c.v[0] != 0xA {
+		t.Errorf("PC=%03X V0=%X, want a break at 200 with V0=A", c.pc, c.v[0])
+	}
+}
+
+// TestWatchpoint saves registers next to a write watchpoint and on it, only the save that writes it breaks.
+func TestWatchpoint(t *testing.T) {
+	c := newTestChip(t, Quirks{LoadStore: IndexUnchanged}, 0xA3, 0x00, 0xF0, 0x55, 0xF1, 0x55, 0xF1, 0x65, 0x12, 0x08)
+	if err := c.AddBreak("write 301"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddBreak("read 300"); err != nil {
+		t.Fatal(err)
+	}
+	mustStep(t, c)
+	mustStep(t, c)
+	if c.debug.broken {
+		t.Fatalf("F055 writing 0x300 broke: %s", c.debug.reason)
+	}
+	mustStep(t, c)
+	if want := "watchpoint write 0x301"; !c.debug.broken || c.debug.reason != want || c.pc != 0x206 {
+		t.Fatalf("after F155 broken=%t %q PC=%03X, want %q after the instruction", c.debug.broken, c.debug.reason, c.pc, want)
+	}
+	c.debug.continueExecution()
+	mustStep(t, c)
+	if want := "watchpoint read 0x300"; c.debug.reason != want {
+		t.Errorf("after F165 %q, want %q", c.debug.reason, want)
+	}
+	if err := c.RemoveBreak("read 300"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Breaks(); len(got) != 1 || got[0] != "write 0x301" {
+		t.Errorf("breaks %q after removing the read watchpoint", got)
+	}
+}
+
+func TestOpcodeBreak(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x60, 0x01, 0xD0, 0x15, 0x12, 0x04)
+	if err := c.AddBreak("op DXYN"); err != nil {
+		t.Fatal(err)
+	}
+	mustStep(t, c)
+	mustStep(t, c)
+	if want := "opcode DXYN (0xD015) at 0x202"; !c.debug.broken || c.debug.reason != want || c.pc != 0x202 || c.cycle != 1 {
+		t.Fatalf("broken=%t %q PC=%03X cycle=%d, want %q before the sprite is drawn", c.debug.broken, c.debug.reason, c.pc, c.cycle, want)
+	}
+	c.debug.continueExecution()
+	mustStep(t, c)
+	if c.pc != 0x204 || c.debug.broken {
+		t.Errorf("continuing from the break: PC=%03X broken=%t, want the sprite drawn", c.pc, c.debug.broken)
+	}
+}
+
+func TestStepOver(t *testing.T) {
+	// 200: call 206, v1 := 1, jump 204; 206: v0 += 1, return
+	c := newTestChip(t, Quirks{}, 0x22, 0x06, 0x61, 0x01, 0x12, 0x04, 0x70, 0x01, 0x00, 0xEE)
+	c.StepOver()
+	waitForBreak(t, c)
+	if c.pc != 0x202 || c.sp != 0 || c.v[0] != 1 || c.debug.reason != "stepped over call" {
+		t.Fatalf("PC=%03X SP=%d V0=%d %q, want the call returned to 202", c.pc, c.sp, c.v[0], c.debug.reason)
+	}
+	c.StepOver()
+	if c.isRunning() || c.pc != 0x204 || c.v[1] != 1 {
+		t.Errorf("stepping over 6101: running=%t PC=%03X V1=%d, want a single step", c.isRunning(), c.pc, c.v[1])
+	}
+}
+
+func TestStepOut(t *testing.T) {
+	// 200: call 206, jump 202; 206: call 20C, v0 += 1, return; 20C: v1 += 1, return
+	c := newTestChip(t, Quirks{}, 0x22, 0x06, 0x12, 0x02, 0x00, 0x00, 0x22, 0x0C, 0x70, 0x01, 0x00, 0xEE, 0x71, 0x01, 0x00, 0xEE)
+	c.StepOut()
+	if c.isRunning() {
+		t.Fatal("stepping out at the top level started the rom")
+	}
+	mustStep(t, c)
+	mustStep(t, c)
+	c.StepOut()
+	waitForBreak(t, c)
+	if c.pc != 0x208 || c.sp != 1 || c.v[1] != 1 || c.v[0] != 0 || c.debug.reason != "stepped out of subroutine" {
+		t.Fatalf("PC=%03X SP=%d V0=%d V1=%d %q, want the return to 208", c.pc, c.sp, c.v[0], c.v[1], c.debug.reason)
+	}
+	c.StepOut()
+	waitForBreak(t, c)
+	if c.pc != 0x202 || c.sp != 0 || c.v[0] != 1 {
+		t.Errorf("PC=%03X SP=%d V0=%d, want the return to 202", c.pc, c.sp, c.v[0])
+	}
+}
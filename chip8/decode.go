@@ -1,11 +1,5 @@
 package chip8
 
-import (
-	"log"
-	"math/rand"
-	"time"
-)
-
 type opcodeParts struct {
 	x   byte
 	y   byte
@@ -20,6 +14,8 @@ type opcodeParts struct {
 // TODO: remove all the c.vChanged[index] = true from here to somewhere else change of forgetting to add it
 //       are too big
 func (c *Chip8) decode() {
+	addr := c.pc
+	c.opcodeAddr = addr
 	c.pc += 2
 	o := opcodeParts{x: xFromOpcode(c.opcode), y: yFromOpcode(c.opcode), nnn: nnnFromOpcode(c.opcode), nn: nnFromOpcode(c.opcode), n: nFromOpcode(c.opcode)}
 	switch c.opcode & 0xF000 {
@@ -27,101 +23,115 @@ func (c *Chip8) decode() {
 		c.decode0x0000(o)
 	case 0x1000:
 		c.pc = o.nnn
-		c.setEmulatorInfo("1NNN", "Flow", "Jumps to address NNN.")
 	case 0x2000:
+		if int(c.sp) >= len(c.stack) {
+			c.fail(StackOverflowError{PC: addr})
+			return
+		}
 		c.stack[c.sp] = c.pc
 		c.sp++
 		c.pc = o.nnn
-		c.setEmulatorInfo("2NNN", "Flow", "Calls subroutine at NNN.")
 	case 0x3000:
 		if c.v[o.x] == byte(o.nn) {
-			c.pc += 2
+			c.skip()
 		}
-		c.setEmulatorInfo("3XNN", "Cond", "Skips the next instruction if VX equals NN. (Usually the next instruction is a jump to skip a code block);")
 	case 0x4000:
 		if c.v[o.x] != o.nn {
-			c.pc += 2
+			c.skip()
 		}
-		c.setEmulatorInfo("4XNN", "Cond", "Skips the next instruction if VX does not equal NN. (Usually the next instruction is a jump to skip a code block);")
 	case 0x5000:
-		if c.v[o.x] == c.v[o.y] {
-			c.pc += 2
-		}
-		c.setEmulatorInfo("5XY0", "Cond", "Skips the next instruction if VX equals VY. (Usually the next instruction is a jump to skip a code block);")
+		c.decode0x5000(o)
 	case 0x6000:
 		c.v[o.x] = o.nn
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("6XNN", "Const", "Sets VX to NN.")
 	case 0x7000:
 		c.v[o.x] += o.nn
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("7XNN", "Const", "Adds NN to VX. (Carry flag is not changed);")
 	case 0x8000:
 		c.decode0x8000(o)
 	case 0x9000:
 		if c.v[o.x] != c.v[o.y] {
-			c.pc += 2
+			c.skip()
 		}
-		c.setEmulatorInfo("9XY0", "Cond", "Skips the next instruction if VX does not equal VY. (Usually the next instruction is a jump to skip a code block);")
 	case 0xA000:
 		c.i = o.nnn
-		c.setEmulatorInfo("ANNN", "MEM", "Sets I to the address NNN.")
 	case 0xB000:
-		c.pc = o.nnn + uint16(c.v[0x0])
-		c.setEmulatorInfo("BNNN", "Flow", "Jumps to the address NNN plus V0.")
+		if c.quirks.Jump {
+			c.pc = o.nnn + uint16(c.v[o.x])
+		} else {
+			c.pc = o.nnn + uint16(c.v[0x0])
+		}
 	case 0xC000:
-		randSource := rand.NewSource(time.Now().UnixNano())
-		r := rand.New(randSource)
-		c.v[o.x] = byte(r.Intn(256)) & o.nn
+		c.v[o.x] = c.rng.next() & o.nn
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("CXNN", "Rand", "Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 255) and NN.")
 	case 0xD000:
-		x := uint16(c.v[o.x])
-		y := uint16(c.v[o.y])
-		h := uint16(o.n)
-		c.draw(x, y, h)
-		c.setEmulatorInfo("DXYN", "Disp", "Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.")
+		if o.n == 0 && c.variant >= VariantSChip {
+			c.draw(c.v[o.x], c.v[o.y], 16, 16)
+		} else {
+			c.draw(c.v[o.x], c.v[o.y], 8, o.n)
+		}
 	case 0xE000:
 		c.decode0xE000(o)
 	case 0xF000:
 		c.decode0xF000(o)
 	default:
-		log.Printf("[ERROR]: Unknown opcode: ox%X\n", c.opcode)
+		c.unknownOpcode()
 	}
 }
+
+func (c *Chip8) unknownOpcode() {
+	c.fail(UnknownOpcodeError{PC: c.execAddr, Opcode: c.opcode})
+}
+func (c *Chip8) decode0x5000(o opcodeParts) {
+	switch {
+	case o.n == 0x0:
+		if c.v[o.x] == c.v[o.y] {
+			c.skip()
+		}
+	case o.n == 0x2 && c.variant >= VariantXOChip:
+		for n, r := range registerRange(o.x, o.y) {
+			c.write(c.i+uint16(n), c.v[r])
+		}
+	case o.n == 0x3 && c.variant >= VariantXOChip:
+		for n, r := range registerRange(o.x, o.y) {
+			c.v[r] = c.read(c.i + uint16(n))
+			c.vChanged[r] = true
+		}
+	default:
+		c.unknownOpcode()
+	}
+}
+
 func (c *Chip8) decode0xE000(o opcodeParts) {
 	switch c.opcode & 0x0FF {
 	case 0x009E:
-		if c.key[c.v[o.x]] == 1 {
-			c.pc += 2
+		if c.key[c.v[o.x]&0xF] == 1 {
+			c.skip()
 		}
-		c.setEmulatorInfo("EX9E", "KeyOp", "Skips the next instruction if the key stored in VX is pressed. (Usually the next instruction is a jump to skip a code block);")
 	case 0x00A1:
-		if c.key[c.v[o.x]] != 1 {
-			c.pc += 2
+		if c.key[c.v[o.x]&0xF] != 1 {
+			c.skip()
 		}
-		c.setEmulatorInfo("EXA1", "KeyOp", "Skips the next instruction if the key stored in VX is not pressed. (Usually the next instruction is a jump to skip a code block);")
+	default:
+		c.unknownOpcode()
 	}
 }
 
 func (c *Chip8) decode0xF000(o opcodeParts) {
+	if c.variant >= VariantXOChip && c.decodeXOChip0xF000(o) {
+		return
+	}
 	switch c.opcode & 0x00FF {
 	case 0x0007:
 		c.v[o.x] = c.delayTimer
-		c.setEmulatorInfo("FX07", "Timer", "Sets VX to the value of the delay timer.")
 	case 0x000A:
-		// FX0A  KeyOp  A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event);
-		// just wait for keyboardInterrupt here?
-		c.pc -= 2
+		c.startKeyWait(o.x)
 	case 0x0015:
 		c.delayTimer = c.v[o.x]
-		c.setEmulatorInfo("FX15", "Timer", "Sets the delay timer to VX.")
 	case 0x0018:
 		c.soundTimer = c.v[o.x]
-		c.setEmulatorInfo("FX18", "Sound", "Sets the sound timer to VX.")
 	case 0x001E:
 		c.i += uint16(c.v[o.x])
-		c.setEmulatorInfo("FX1E", "MEM", "Adds VX to I. VF is not affected.")
 	case 0x0029:
 		var loc uint16
 		for i := byte(0x0); i < 0x10; i++ {
@@ -130,7 +140,12 @@ func (c *Chip8) decode0xF000(o opcodeParts) {
 			}
 			loc += 5
 		}
-		c.setEmulatorInfo("FX29", "MEM", "Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font.")
+	case 0x0030:
+		if c.variant < VariantSChip {
+			c.unknownOpcode()
+			return
+		}
+		c.i = bigFontAddr + uint16(c.v[o.x]&0xF)*10
 	case 0x0033:
 		/*
 			Store BCD representation of Vx in I
@@ -139,29 +154,61 @@ func (c *Chip8) decode0xF000(o opcodeParts) {
 			0X0 = I+1
 			00X = I+2
 		*/
-		c.memory[c.i] = c.v[o.x] / 100
-		c.memory[c.i+1] = (c.v[o.x] / 10) % 10
-		c.memory[c.i+2] = (c.v[o.x] % 100) % 10
-		c.setEmulatorInfo("FX33", "BCD", "Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the least significant digit at I plus 2. (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit at location I+1, and the ones digit at location I+2.);")
-	case 0x0055: // TODO: FX55 should we increment I here?
+		c.write(c.i, c.v[o.x]/100)
+		c.write(c.i+1, (c.v[o.x]/10)%10)
+		c.write(c.i+2, (c.v[o.x]%100)%10)
+	case 0x0055:
 		for i := byte(0x0); i <= o.x; i++ {
-			c.memory[c.i] = c.v[i]
-			c.i++
+			c.write(c.i+uint16(i), c.v[i])
 		}
-		c.i++
-		c.setEmulatorInfo("FX55", "MEM", "Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.")
-	case 0x0065: // TODO: FX65 should we increment I here?
-		iCounter := c.i
+		c.incrementIndex(o.x)
+	case 0x0065:
 		for i := byte(0x0); i <= o.x; i++ {
-			//c.v[i] = c.memory[c.i]
-			c.v[i] = c.memory[iCounter]
+			c.v[i] = c.read(c.i + uint16(i))
 			c.vChanged[i] = true
-			//c.i++
-			iCounter++
 		}
-		//c.i++
-		c.setEmulatorInfo("FX65", "MEM", "Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.")
+		c.incrementIndex(o.x)
+	case 0x0075:
+		if c.variant < VariantSChip {
+			c.unknownOpcode()
+			return
+		}
+		for i := byte(0x0); i <= o.x && int(i) < c.rplSize(); i++ {
+			c.rpl[i] = c.v[i]
+		}
+	case 0x0085:
+		if c.variant < VariantSChip {
+			c.unknownOpcode()
+			return
+		}
+		for i := byte(0x0); i <= o.x && int(i) < c.rplSize(); i++ {
+			c.v[i] = c.rpl[i]
+			c.vChanged[i] = true
+		}
+	default:
+		c.unknownOpcode()
+	}
+}
+
+// decodeXOChip0xF000 decodes the XO-CHIP memory, plane and audio opcodes, it returns false for the opcodes
+// shared with CHIP-8 and SUPER-CHIP.
+func (c *Chip8) decodeXOChip0xF000(o opcodeParts) bool {
+	switch {
+	case c.opcode == 0xF000:
+		c.i = uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+		c.pc += 2
+	case o.nn == 0x01:
+		c.planes = o.x & 0x3
+	case c.opcode == 0xF002:
+		for i := range c.audioPattern {
+			c.audioPattern[i] = c.read(c.i + uint16(i))
+		}
+	case o.nn == 0x3A:
+		c.pitch = c.v[o.x]
+	default:
+		return false
 	}
+	return true
 }
 
 func (c *Chip8) decode0x8000(o opcodeParts) {
@@ -169,19 +216,18 @@ func (c *Chip8) decode0x8000(o opcodeParts) {
 	case 0x0000:
 		c.v[o.x] = c.v[o.y]
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY0", "Assig", "Sets VX to the value of VY.")
 	case 0x0001:
 		c.v[o.x] |= c.v[o.y]
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY1", "BitOp", "Sets VX to VX or VY. (Bitwise OR operation);")
+		c.resetVF()
 	case 0x0002:
 		c.v[o.x] &= c.v[o.y]
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY2", "BitOp", "Sets VX to VX and VY. (Bitwise AND operation);")
+		c.resetVF()
 	case 0x0003:
 		c.v[o.x] ^= c.v[o.y]
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY3", "BitOp", "Sets VX to VX xor VY. (Bitwise XOR operation);")
+		c.resetVF()
 	case 0x0004:
 		if c.v[o.x] > (0xFF - c.v[o.y]) {
 			c.v[0xF] = 1
@@ -191,77 +237,229 @@ func (c *Chip8) decode0x8000(o opcodeParts) {
 		c.vChanged[0xF] = true
 		c.v[o.x] += c.v[o.y]
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY4", "Math", "Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there is not.")
 	case 0x0005: // TODO: double check 8XY5
 		c.subtract(o.x, o.x, o.y)
-		c.setEmulatorInfo("8XY5", "Math", "VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there is not.")
 	case 0x0006:
-		if c.v[o.x]&0x01 == 1 {
-			c.v[0xF] = 1
-		} else {
-			c.v[0xF] = 0
-		}
-		c.vChanged[0xF] = true
-		c.v[o.x] >>= 1
+		src := c.shiftSource(o)
+		flag := c.v[src] & 0x01
+		c.v[o.x] = c.v[src] >> 1
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XY6", "BitOp", "Stores the least significant bit of VX in VF and then shifts VX to the right by 1.")
+		c.v[0xF] = flag
+		c.vChanged[0xF] = true
 	case 0x0007:
 		c.subtract(o.x, o.y, o.x)
-		c.setEmulatorInfo("8XY7", "Math", "Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there is not.")
 	case 0x000E:
-		if c.v[o.x]&0x8 == 1 {
-			c.v[0xF] = 1
-		} else {
-			c.v[0xF] = 0
-		}
-		c.vChanged[0xF] = true
-		c.v[o.x] <<= 1
+		src := c.shiftSource(o)
+		flag := c.v[src] >> 7
+		c.v[o.x] = c.v[src] << 1
 		c.vChanged[o.x] = true
-		c.setEmulatorInfo("8XYE", "BitOp", "Stores the most significant bit of VX in VF and then shifts VX to the left by 1.")
+		c.v[0xF] = flag
+		c.vChanged[0xF] = true
 	default:
-		log.Printf("[ERROR]: Unknown opcode: ox%X\n", c.opcode)
-
+		c.unknownOpcode()
 	}
 }
 
 func (c *Chip8) decode0x0000(o opcodeParts) {
-	switch c.opcode & 0x00FF {
+	if c.variant >= VariantSChip && c.decodeSChip0x0000(o) {
+		return
+	}
+	switch c.opcode {
 	case 0x00E0:
-		c.clearScreen()
-		c.setEmulatorInfo("00E0", "Display", "Clears the screen.")
+		c.clearPlanes()
 	case 0x00EE:
+		if c.sp == 0 {
+			c.fail(StackUnderflowError{PC: c.execAddr})
+			return
+		}
 		c.sp--
 		c.pc = c.stack[c.sp]
-		c.setEmulatorInfo("00EE", "Flow", "Returns from a subroutine.")
 	default:
-		c.setEmulatorInfo("0NNN", "Call", "Calls machine code routine. Not implemented")
 	}
 }
 
-func (c *Chip8) draw(x, y, h uint16) {
-	var pixel uint16
+// decodeSChip0x0000 decodes the SUPER-CHIP display and exit opcodes, it returns false for the opcodes
+// shared with CHIP-8.
+func (c *Chip8) decodeSChip0x0000(o opcodeParts) bool {
+	if c.opcode&0xFFF0 == 0x00C0 {
+		c.scrollDown(int(o.n))
+		return true
+	}
+	if c.opcode&0xFFF0 == 0x00D0 && c.variant >= VariantXOChip {
+		c.scrollUp(int(o.n))
+		return true
+	}
+	switch c.opcode {
+	case 0x00FB:
+		c.scrollRight(4)
+	case 0x00FC:
+		c.scrollLeft(4)
+	case 0x00FD:
+		c.exit()
+	case 0x00FE:
+		c.setResolution(false)
+	case 0x00FF:
+		c.setResolution(true)
+	default:
+		return false
+	}
+	return true
+}
+
+// draw xors a sprite of w (8 or 16) by h pixels starting at I onto the selected planes of the screen. With two planes
+// selected the sprite data for the second plane follows the first. The starting position always wraps, pixels past
+// the edges are clipped or wrapped depending on the clip quirk.
+func (c *Chip8) draw(vx, vy, w, h byte) {
+	x := int(vx) % c.width
+	y := int(vy) % c.height
+	bytesPerRow := uint16(w / 8)
+	addr := c.i
+	c.v[0xF] = 0
+	for plane := byte(1); plane <= 2; plane <<= 1 {
+		if c.planes&plane == 0 {
+			continue
+		}
+		c.drawPlane(addr, plane, x, y, w, h)
+		addr += bytesPerRow * uint16(h)
+	}
+	c.drawFlag = true
+	c.spriteDrawn = true
+	c.vChanged[0xF] = true
+}
+
+func (c *Chip8) drawPlane(addr uint16, plane byte, x, y int, w, h byte) {
+	bytesPerRow := uint16(w / 8)
 	for yLine := 0; yLine < int(h); yLine++ {
-		pixel = uint16(c.memory[c.i+uint16(yLine)]) // Fetch the pixel value from the memory starting at location I
-		for xLine := 0; xLine < 8; xLine++ {
-			if (pixel & (0x80 >> xLine)) != 0 {
-				index := (x + uint16(xLine) + ((y + uint16(yLine)) * screenWidth))
-				if index < uint16(len(c.screenBuf)) {
-					if c.screenBuf[index] == 1 { // Check if the pixel on the display is set to 1. If it is set,
-						c.v[0xF] = 1 // we need to register the collision by setting the VF register
-						c.vChanged[0xF] = true
-					}
-					c.screenBuf[x+uint16(xLine)+((y+uint16(yLine))*screenWidth)] ^= 1
+		// Fetch the pixel value from the memory starting at location I
+		var pixel uint16
+		for b := uint16(0); b < bytesPerRow; b++ {
+			pixel = pixel<<8 | uint16(c.read(addr+uint16(yLine)*bytesPerRow+b))
+		}
+		py := y + yLine
+		if py >= c.height {
+			if c.quirks.Clip {
+				break
+			}
+			py %= c.height
+		}
+		for xLine := 0; xLine < int(w); xLine++ {
+			if (pixel & (1 << (int(w) - 1 - xLine))) == 0 {
+				continue
+			}
+			px := x + xLine
+			if px >= c.width {
+				if c.quirks.Clip {
+					continue
 				}
+				px %= c.width
+			}
+			index := px + py*c.width
+			if c.screenBuf[index]&plane != 0 { // Check if the pixel on the display is set to 1. If it is set,
+				c.v[0xF] = 1 // we need to register the collision by setting the VF register
 			}
+			c.setPixel(index, c.screenBuf[index]^plane)
+		}
+	}
+}
+
+func (c *Chip8) scrollDown(n int) {
+	c.scroll(0, n)
+}
+
+func (c *Chip8) scrollUp(n int) {
+	c.scroll(0, -n)
+}
+
+func (c *Chip8) scrollRight(n int) {
+	c.scroll(n, 0)
+}
+
+func (c *Chip8) scrollLeft(n int) {
+	c.scroll(-n, 0)
+}
+
+// scroll moves the selected planes dx pixels to the right and dy pixels down, the uncovered pixels are cleared.
+func (c *Chip8) scroll(dx, dy int) {
+	src := c.screenBuf
+	for y := 0; y < c.height; y++ {
+		for x := 0; x < c.width; x++ {
+			var p byte
+			sx, sy := x-dx, y-dy
+			if sx >= 0 && sx < c.width && sy >= 0 && sy < c.height {
+				p = src[sx+sy*c.width]
+			}
+			index := x + y*c.width
+			c.setPixel(index, c.screenBuf[index]&^c.planes|p&c.planes)
 		}
 	}
 	c.drawFlag = true
-	c.vChanged[0xF] = true
 }
 
 func (c *Chip8) clearScreen() {
 	for i := range c.screenBuf {
-		c.screenBuf[i] = 0
+		c.setPixel(i, 0)
+	}
+}
+
+func (c *Chip8) clearPlanes() {
+	for i := range c.screenBuf {
+		c.setPixel(i, c.screenBuf[i]&^c.planes)
+	}
+	c.drawFlag = true
+}
+
+// skip skips the next instruction, on XO-CHIP the 4 byte F000 NNNN instruction is skipped as a whole.
+func (c *Chip8) skip() {
+	if c.variant >= VariantXOChip && c.memory[c.pc] == 0xF0 && c.memory[c.pc+1] == 0x00 {
+		c.pc += 4
+	} else {
+		c.pc += 2
+	}
+}
+
+func (c *Chip8) rplSize() int {
+	if c.variant >= VariantXOChip {
+		return len(c.rpl)
+	}
+	return schipRPLSize
+}
+
+// registerRange returns the registers from x to y, in descending order when x is bigger than y.
+func registerRange(x, y byte) []byte {
+	var r []byte
+	if x <= y {
+		for i := x; i <= y; i++ {
+			r = append(r, i)
+		}
+	} else {
+		for i := int(x); i >= int(y); i-- {
+			r = append(r, byte(i))
+		}
+	}
+	return r
+}
+
+// shiftSource returns the register 8XY6 and 8XYE shift, VX with the shift quirk and VY otherwise.
+func (c *Chip8) shiftSource(o opcodeParts) byte {
+	if c.quirks.Shift {
+		return o.x
+	}
+	return o.y
+}
+
+func (c *Chip8) resetVF() {
+	if c.quirks.VFReset {
+		c.v[0xF] = 0
+		c.vChanged[0xF] = true
+	}
+}
+
+func (c *Chip8) incrementIndex(x byte) {
+	switch c.quirks.LoadStore {
+	case IndexIncrementX1:
+		c.i += uint16(x) + 1
+	case IndexIncrementX:
+		c.i += uint16(x)
 	}
 }
 
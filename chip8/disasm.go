@@ -0,0 +1,361 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Syntax is the assembly syntax instructions are formatted in.
+type Syntax int
+
+const (
+	SyntaxOcto    Syntax = iota // the syntax of the Octo assembler, e.g. v0 := 0x05
+	SyntaxClassic               // the mnemonics of Cowgod's technical reference, e.g. LD V0, 0x05
+)
+
+var syntaxNames = map[Syntax]string{
+	SyntaxOcto:    "octo",
+	SyntaxClassic: "classic",
+}
+
+func (s Syntax) String() string {
+	return syntaxNames[s]
+}
+
+func ParseSyntax(s string) (Syntax, error) {
+	for syntax, name := range syntaxNames {
+		if strings.EqualFold(name, s) {
+			return syntax, nil
+		}
+	}
+	return SyntaxOcto, UnknownSyntaxError{Name: s, Valid: SyntaxNames()}
+}
+
+func SyntaxNames() []string {
+	return []string{SyntaxOcto.String(), SyntaxClassic.String()}
+}
+
+// opcodeDef describes an opcode, the operands in the syntax templates are {x}, {y}, {n}, {nn}, {nnn} and {nnnn},
+// {xn} is X written as a number and {hi} and {lo} are the bytes of the opcode for instructions that are written as data.
+type opcodeDef struct {
+	typ     string
+	desc    string
+	octo    string
+	classic string
+}
+
+const unknownOpcodeName = "????"
+
+var opcodeDefs = map[string]opcodeDef{
+	"0NNN": {"Call", "Calls machine code routine. Not implemented", "0x{hi} 0x{lo}", "SYS {nnn}"},
+	"00E0": {"Display", "Clears the screen.", "clear", "CLS"},
+	"00EE": {"Flow", "Returns from a subroutine.", "return", "RET"},
+	"00CN": {"Display", "Scrolls the display down by N pixels.", "scroll-down {n}", "SCD {n}"},
+	"00DN": {"Display", "Scrolls the display up by N pixels.", "scroll-up {n}", "SCU {n}"},
+	"00FB": {"Display", "Scrolls the display right by 4 pixels.", "scroll-right", "SCR"},
+	"00FC": {"Display", "Scrolls the display left by 4 pixels.", "scroll-left", "SCL"},
+	"00FD": {"Flow", "Exits the interpreter.", "exit", "EXIT"},
+	"00FE": {"Display", "Switches to the 64x32 low resolution mode.", "lores", "LOW"},
+	"00FF": {"Display", "Switches to the 128x64 high resolution mode.", "hires", "HIGH"},
+	"1NNN": {"Flow", "Jumps to address NNN.", "jump {nnn}", "JP {nnn}"},
+	"2NNN": {"Flow", "Calls subroutine at NNN.", ":call {nnn}", "CALL {nnn}"},
+	"3XNN": {"Cond", "Skips the next instruction if VX equals NN. (Usually the next instruction is a jump to skip a code block);", "if v{x} != {nn} then", "SE V{x}, {nn}"},
+	"4XNN": {"Cond", "Skips the next instruction if VX does not equal NN. (Usually the next instruction is a jump to skip a code block);", "if v{x} == {nn} then", "SNE V{x}, {nn}"},
+	"5XY0": {"Cond", "Skips the next instruction if VX equals VY. (Usually the next instruction is a jump to skip a code block);", "if v{x} != v{y} then", "SE V{x}, V{y}"},
+	"5XY2": {"MEM", "Stores VX to VY (including VY) in memory starting at address I, I is left unmodified.", "save v{x} - v{y}", "LD [I], V{x}-V{y}"},
+	"5XY3": {"MEM", "Fills VX to VY (including VY) with values from memory starting at address I, I is left unmodified.", "load v{x} - v{y}", "LD V{x}-V{y}, [I]"},
+	"6XNN": {"Const", "Sets VX to NN.", "v{x} := {nn}", "LD V{x}, {nn}"},
+	"7XNN": {"Const", "Adds NN to VX. (Carry flag is not changed);", "v{x} += {nn}", "ADD V{x}, {nn}"},
+	"8XY0": {"Assig", "Sets VX to the value of VY.", "v{x} := v{y}", "LD V{x}, V{y}"},
+	"8XY1": {"BitOp", "Sets VX to VX or VY. (Bitwise OR operation);", "v{x} |= v{y}", "OR V{x}, V{y}"},
+	"8XY2": {"BitOp", "Sets VX to VX and VY. (Bitwise AND operation);", "v{x} &= v{y}", "AND V{x}, V{y}"},
+	"8XY3": {"BitOp", "Sets VX to VX xor VY. (Bitwise XOR operation);", "v{x} ^= v{y}", "XOR V{x}, V{y}"},
+	"8XY4": {"Math", "Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there is not.", "v{x} += v{y}", "ADD V{x}, V{y}"},
+	"8XY5": {"Math", "VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there is not.", "v{x} -= v{y}", "SUB V{x}, V{y}"},
+	"8XY6": {"BitOp", "Stores the least significant bit of VX in VF and then shifts VX to the right by 1.", "v{x} >>= v{y}", "SHR V{x}, V{y}"},
+	"8XY7": {"Math", "Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there is not.", "v{x} =- v{y}", "SUBN V{x}, V{y}"},
+	"8XYE": {"BitOp", "Stores the most significant bit of VX in VF and then shifts VX to the left by 1.", "v{x} <<= v{y}", "SHL V{x}, V{y}"},
+	"9XY0": {"Cond", "Skips the next instruction if VX does not equal VY. (Usually the next instruction is a jump to skip a code block);", "if v{x} == v{y} then", "SNE V{x}, V{y}"},
+	"ANNN": {"MEM", "Sets I to the address NNN.", "i := {nnn}", "LD I, {nnn}"},
+	"BNNN": {"Flow", "Jumps to the address NNN plus V0.", "jump0 {nnn}", "JP V0, {nnn}"},
+	"CXNN": {"Rand", "Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 255) and NN.", "v{x} := random {nn}", "RND V{x}, {nn}"},
+	"DXYN": {"Disp", "Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.", "sprite v{x} v{y} {n}", "DRW V{x}, V{y}, {n}"},
+	"DXY0": {"Disp", "Draws a 16x16 sprite at coordinate (VX, VY).", "sprite v{x} v{y} 0", "DRW V{x}, V{y}, 0"},
+	"EX9E": {"KeyOp", "Skips the next instruction if the key stored in VX is pressed. (Usually the next instruction is a jump to skip a code block);", "if v{x} -key then", "SKP V{x}"},
+	"EXA1": {"KeyOp", "Skips the next instruction if the key stored in VX is not pressed. (Usually the next instruction is a jump to skip a code block);", "if v{x} key then", "SKNP V{x}"},
+	"F000": {"MEM", "Sets I to the 16 bit address NNNN stored in the next two bytes.", "i := long {nnnn}", "LD I, long {nnnn}"},
+	"FN01": {"Disp", "Selects the bitplanes N (0-3) used by drawing, clearing and scrolling.", "plane {xn}", "PLANE {xn}"},
+	"F002": {"Sound", "Loads the 16 byte audio pattern buffer from memory starting at address I.", "audio", "AUDIO"},
+	"FX07": {"Timer", "Sets VX to the value of the delay timer.", "v{x} := delay", "LD V{x}, DT"},
+	"FX0A": {"KeyOp", "A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event);", "v{x} := key", "LD V{x}, K"},
+	"FX15": {"Timer", "Sets the delay timer to VX.", "delay := v{x}", "LD DT, V{x}"},
+	"FX18": {"Sound", "Sets the sound timer to VX.", "buzzer := v{x}", "LD ST, V{x}"},
+	"FX1E": {"MEM", "Adds VX to I. VF is not affected.", "i += v{x}", "ADD I, V{x}"},
+	"FX29": {"MEM", "Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font.", "i := hex v{x}", "LD F, V{x}"},
+	"FX30": {"MEM", "Sets I to the location of the 8x10 sprite for the character in VX.", "i := bighex v{x}", "LD HF, V{x}"},
+	"FX33": {"BCD", "Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the least significant digit at I plus 2. (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit at location I+1, and the ones digit at location I+2.);", "bcd v{x}", "LD B, V{x}"},
+	"FX3A": {"Sound", "Sets the audio pattern playback pitch to VX.", "pitch := v{x}", "PITCH V{x}"},
+	"FX55": {"MEM", "Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.", "save v{x}", "LD [I], V{x}"},
+	"FX65": {"MEM", "Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.", "load v{x}", "LD V{x}, [I]"},
+	"FX75": {"MEM", "Stores V0 to VX (X <= 7, X <= F on XO-CHIP) in the RPL user flags.", "saveflags v{x}", "LD R, V{x}"},
+	"FX85": {"MEM", "Fills V0 to VX (X <= 7, X <= F on XO-CHIP) with the RPL user flags.", "loadflags v{x}", "LD V{x}, R"},
+	"????": {"Unknown", "Unknown opcode.", "0x{hi} 0x{lo}", "DW {opcode}"},
+}
+
+// Instruction is a disassembled opcode.
+type Instruction struct {
+	Addr   uint16
+	Opcode uint16
+	Long   uint16 // NNNN of the XO-CHIP F000 NNNN instruction, which is 4 bytes long
+	Name   string // the opcode pattern, e.g. DXYN, or ???? for unknown opcodes
+	Type   string
+	Desc   string
+}
+
+// Disassemble decodes an opcode of any variant, it does not execute anything. The operand of F000 NNNN is not part
+// of the opcode, use DisassembleAt to read it from memory.
+func Disassemble(opcode uint16, addr uint16) Instruction {
+	return VariantXOChip.Disassemble(opcode, addr)
+}
+
+// Disassemble decodes an opcode the way the decoder of the variant executes it.
+func (v Variant) Disassemble(opcode uint16, addr uint16) Instruction {
+	name := v.opcodeName(opcode)
+	def := opcodeDefs[name]
+	return Instruction{Addr: addr, Opcode: opcode, Name: name, Type: def.typ, Desc: def.desc}
+}
+
+// DisassembleAt decodes the instruction at addr in memory.
+func (v Variant) DisassembleAt(memory []byte, addr uint16) Instruction {
+	in := v.Disassemble(wordAt(memory, int(addr)), addr)
+	if in.Name == "F000" {
+		in.Long = wordAt(memory, int(addr)+2)
+	}
+	return in
+}
+
+func wordAt(memory []byte, addr int) uint16 {
+	var w uint16
+	for i := addr; i < addr+2; i++ {
+		w <<= 8
+		if i < len(memory) {
+			w |= uint16(memory[i])
+		}
+	}
+	return w
+}
+
+// Size is the number of bytes of the instruction.
+func (in Instruction) Size() int {
+	if in.Name == "F000" {
+		return 4
+	}
+	return 2
+}
+
+// Target returns the address a jump or call goes to, BNNN goes to the returned address plus V0.
+func (in Instruction) Target() (uint16, bool) {
+	switch in.Name {
+	case "1NNN", "2NNN", "BNNN":
+		return nnnFromOpcode(in.Opcode), true
+	}
+	return 0, false
+}
+
+// Valid reports whether the opcode is known to the variant it was disassembled for.
+func (in Instruction) Valid() bool {
+	return in.Name != unknownOpcodeName
+}
+
+// Format returns the instruction in the syntax.
+func (in Instruction) Format(s Syntax) string {
+	return in.FormatSymbols(s, nil)
+}
+
+// FormatSymbols formats the instruction like Format, the address operands that have a label are written as the label.
+func (in Instruction) FormatSymbols(s Syntax, syms *Symbols) string {
+	addr := func(a uint16, format string) string {
+		if name, ok := syms.Name(a); ok {
+			return name
+		}
+		return fmt.Sprintf(format, a)
+	}
+	def := opcodeDefs[in.Name]
+	template := def.octo
+	if s == SyntaxClassic {
+		template = def.classic
+	}
+	return strings.NewReplacer(
+		"{x}", fmt.Sprintf("%X", xFromOpcode(in.Opcode)),
+		"{xn}", fmt.Sprintf("%d", xFromOpcode(in.Opcode)),
+		"{y}", fmt.Sprintf("%X", yFromOpcode(in.Opcode)),
+		"{n}", fmt.Sprintf("%d", nFromOpcode(in.Opcode)),
+		"{nn}", fmt.Sprintf("0x%02X", nnFromOpcode(in.Opcode)),
+		"{nnn}", addr(nnnFromOpcode(in.Opcode), "0x%03X"),
+		"{nnnn}", addr(in.Long, "0x%04X"),
+		"{hi}", fmt.Sprintf("%02X", in.Opcode>>8),
+		"{lo}", fmt.Sprintf("%02X", in.Opcode&0xFF),
+		"{opcode}", fmt.Sprintf("0x%04X", in.Opcode),
+	).Replace(template)
+}
+
+func (in Instruction) String() string {
+	return in.Format(SyntaxOcto)
+}
+
+// opcodeName returns the pattern of the opcode as decode executes it for the variant.
+func (v Variant) opcodeName(opcode uint16) string {
+	n := nFromOpcode(opcode)
+	nn := nnFromOpcode(opcode)
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch {
+		case v >= VariantSChip && opcode&0xFFF0 == 0x00C0:
+			return "00CN"
+		case v >= VariantXOChip && opcode&0xFFF0 == 0x00D0:
+			return "00DN"
+		case v >= VariantSChip && opcode >= 0x00FB && opcode <= 0x00FF:
+			return fmt.Sprintf("%04X", opcode)
+		case opcode == 0x00E0 || opcode == 0x00EE:
+			return fmt.Sprintf("%04X", opcode)
+		}
+		return "0NNN"
+	case 0x5000:
+		switch {
+		case n == 0x0:
+			return "5XY0"
+		case n == 0x2 && v >= VariantXOChip:
+			return "5XY2"
+		case n == 0x3 && v >= VariantXOChip:
+			return "5XY3"
+		}
+	case 0x8000:
+		switch n {
+		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0xE:
+			return fmt.Sprintf("8XY%X", n)
+		}
+	case 0xD000:
+		if n == 0 && v >= VariantSChip {
+			return "DXY0"
+		}
+		return "DXYN"
+	case 0xE000:
+		switch nn {
+		case 0x9E:
+			return "EX9E"
+		case 0xA1:
+			return "EXA1"
+		}
+	case 0xF000:
+		if v >= VariantXOChip {
+			switch {
+			case opcode == 0xF000 || opcode == 0xF002:
+				return fmt.Sprintf("%04X", opcode)
+			case nn == 0x01:
+				return "FN01"
+			case nn == 0x3A:
+				return "FX3A"
+			}
+		}
+		switch nn {
+		case 0x07, 0x0A, 0x15, 0x18, 0x1E, 0x29, 0x33, 0x55, 0x65:
+			return fmt.Sprintf("FX%02X", nn)
+		case 0x30, 0x75, 0x85:
+			if v >= VariantSChip {
+				return fmt.Sprintf("FX%02X", nn)
+			}
+		}
+	case 0x1000:
+		return "1NNN"
+	case 0x2000:
+		return "2NNN"
+	case 0x3000:
+		return "3XNN"
+	case 0x4000:
+		return "4XNN"
+	case 0x6000:
+		return "6XNN"
+	case 0x7000:
+		return "7XNN"
+	case 0x9000:
+		return "9XY0"
+	case 0xA000:
+		return "ANNN"
+	case 0xB000:
+		return "BNNN"
+	case 0xC000:
+		return "CXNN"
+	}
+	return unknownOpcodeName
+}
+
+// Line is a line of a disassembly listing, an instruction or a few bytes of data.
+type Line struct {
+	Addr        uint16
+	Bytes       []byte
+	Data        bool
+	Instruction Instruction
+}
+
+// maxDataBytes is the number of data bytes listed on one line.
+const maxDataBytes = 4
+
+// Listing disassembles a rom loaded at addr. Code is found by tracing the instructions reachable from addr, the
+// bytes that are never reached are listed as data.
+func (v Variant) Listing(rom []byte, addr uint16) []Line {
+	code := v.trace(rom, addr)
+	var lines []Line
+	for offset := 0; offset < len(rom); {
+		if code[offset] {
+			in := v.DisassembleAt(rom, uint16(offset))
+			in.Addr = addr + uint16(offset)
+			end := offset + in.Size()
+			if end > len(rom) {
+				end = len(rom)
+			}
+			lines = append(lines, Line{Addr: in.Addr, Bytes: rom[offset:end], Instruction: in})
+			offset = end
+			continue
+		}
+		end := offset + 1
+		for end < len(rom) && end-offset < maxDataBytes && !code[end] {
+			end++
+		}
+		lines = append(lines, Line{Addr: addr + uint16(offset), Bytes: rom[offset:end], Data: true})
+		offset = end
+	}
+	return lines
+}
+
+// trace marks the offsets in the rom where a reachable instruction starts. Jumps and calls are followed, both
+// sides of a skip are taken and BNNN ends the trace because its target is only known while running.
+func (v Variant) trace(rom []byte, addr uint16) []bool {
+	code := make([]bool, len(rom))
+	todo := []int{0}
+	for len(todo) > 0 {
+		offset := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if offset < 0 || offset+1 >= len(rom) || code[offset] {
+			continue
+		}
+		in := v.DisassembleAt(rom, uint16(offset))
+		if !in.Valid() {
+			continue
+		}
+		code[offset] = true
+		next := offset + in.Size()
+		target := int(nnnFromOpcode(in.Opcode)) - int(addr)
+		switch in.Name {
+		case "00EE", "00FD", "BNNN":
+		case "1NNN":
+			todo = append(todo, target)
+		case "2NNN":
+			todo = append(todo, next, target)
+		case "3XNN", "4XNN", "5XY0", "9XY0", "EX9E", "EXA1":
+			todo = append(todo, next, next+v.DisassembleAt(rom, uint16(next)).Size())
+		default:
+			todo = append(todo, next)
+		}
+	}
+	return code
+}
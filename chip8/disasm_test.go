@@ -0,0 +1,55 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MickLuypaerts/chip8Emu/assembler"
+)
+
+// TestDisassembleRoundTrip disassembles every opcode of every variant in Octo syntax and assembles the listing again,
+// which has to give back the same bytes or an opcode that is executed the same way. The opcodes are assembled by their
+// first nibble so the listing fits in memory.
+func TestDisassembleRoundTrip(t *testing.T) {
+	for _, name := range VariantNames() {
+		v, _ := ParseVariant(name)
+		seen := make(map[string]bool)
+		for hi := 0; hi <= 0xF; hi++ {
+			var rom []byte
+			var src strings.Builder
+			for op := hi << 12; op < (hi+1)<<12; op++ {
+				code := []byte{byte(op >> 8), byte(op), 0x12, 0x34}
+				in := v.DisassembleAt(code, 0)
+				code = code[:in.Size()]
+				seen[in.Name] = true
+				rom = append(rom, code...)
+				fmt.Fprintln(&src, in.Format(SyntaxOcto))
+			}
+			prog, err := assembler.Assemble(src.String())
+			if err != nil {
+				t.Fatalf("%s: assembling the disassembly of %XXXX: %v", name, hi, err)
+			}
+			if len(prog.ROM) != len(rom) {
+				t.Fatalf("%s: the assembled %XXXX opcodes are %d bytes, want %d", name, hi, len(prog.ROM), len(rom))
+			}
+			// opcodes the decoder executes like another one, 9XY1 runs as 9XY0, assemble to that opcode
+			for i := 0; i < len(rom); {
+				in := v.DisassembleAt(rom, uint16(i))
+				out := v.DisassembleAt(prog.ROM, uint16(i))
+				if !bytes.Equal(prog.ROM[i:i+in.Size()], rom[i:i+in.Size()]) && out.Format(SyntaxOcto) != in.Format(SyntaxOcto) {
+					t.Fatalf("%s: %q assembles to %q", name, in.Format(SyntaxOcto), out.Format(SyntaxOcto))
+				}
+				i += in.Size()
+			}
+		}
+		if v == VariantXOChip {
+			for def := range opcodeDefs {
+				if !seen[def] {
+					t.Errorf("%s: no opcode disassembles as %s", name, def)
+				}
+			}
+		}
+	}
+}
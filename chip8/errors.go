@@ -0,0 +1,333 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type UnknownQuirkError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownQuirkError) Error() string {
+	return "unknown quirk: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type InvalidQuirkValueError struct {
+	Name  string
+	Value string
+}
+
+func (e InvalidQuirkValueError) Error() string {
+	return "invalid value for quirk " + e.Name + ": " + e.Value
+}
+
+type UnknownQuirksPresetError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownQuirksPresetError) Error() string {
+	return "unknown quirks preset: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type UnknownVariantError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownVariantError) Error() string {
+	return "unknown machine variant: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type InvalidStateError struct {
+	Err error
+}
+
+func (e InvalidStateError) Error() string {
+	if e.Err != nil {
+		return "invalid save state: " + e.Err.Error()
+	}
+	return "invalid save state"
+}
+
+type StateVersionError struct {
+	Version int
+}
+
+func (e StateVersionError) Error() string {
+	return "unsupported save state version: " + strconv.Itoa(e.Version)
+}
+
+type StateROMMismatchError struct{}
+
+func (e StateROMMismatchError) Error() string {
+	return "save state belongs to another rom"
+}
+
+type UnknownSyntaxError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownSyntaxError) Error() string {
+	return "unknown assembly syntax: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type InvalidBreakpointError struct {
+	Spec string
+}
+
+func (e InvalidBreakpointError) Error() string {
+	return "invalid breakpoint: " + e.Spec + " (valid: ADDR, ADDR if REG OP VALUE, read|write|access ADDR, op PATTERN)"
+}
+
+type InvalidConditionError struct {
+	Condition string
+}
+
+func (e InvalidConditionError) Error() string {
+	return "invalid condition: " + e.Condition + " (e.g. v3 == 10, i >= 300)"
+}
+
+type UnknownRegisterError struct {
+	Name string
+}
+
+func (e UnknownRegisterError) Error() string {
+	return "unknown register: " + e.Name + " (valid: v0-vf, i, pc, dt, st)"
+}
+
+type InvalidByteError struct {
+	Value string
+}
+
+func (e InvalidByteError) Error() string {
+	return "invalid byte: " + e.Value + " (valid: 00-ff)"
+}
+
+type MemoryBoundsError struct {
+	Addr int
+	Size int
+}
+
+func (e MemoryBoundsError) Error() string {
+	return fmt.Sprintf("address 0x%X is out of memory (size 0x%X)", e.Addr, e.Size)
+}
+
+type InvalidSymbolError struct {
+	Line int // 0 for JSON symbol files
+	Text string
+}
+
+func (e InvalidSymbolError) Error() string {
+	if e.Line == 0 {
+		return "invalid symbol: " + e.Text
+	}
+	return fmt.Sprintf("line %d: invalid symbol: %s (valid: ADDR NAME)", e.Line, e.Text)
+}
+
+type UnknownTraceFormatError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownTraceFormatError) Error() string {
+	return "unknown trace format: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type InvalidTraceRangeError struct {
+	Range string
+}
+
+func (e InvalidTraceRangeError) Error() string {
+	return "invalid trace range: " + e.Range + " (e.g. 200-2FF)"
+}
+
+type InvalidTraceError struct {
+	Line int // 0 for binary traces
+	Msg  string
+}
+
+func (e InvalidTraceError) Error() string {
+	if e.Line == 0 {
+		return "invalid trace: " + e.Msg
+	}
+	return fmt.Sprintf("invalid trace line %d: %s", e.Line, e.Msg)
+}
+
+// TraceReadError is an error reading one of the traces that are compared, Trace is 0 or 1.
+type TraceReadError struct {
+	Trace int
+	Err   error
+}
+
+func (e TraceReadError) Error() string {
+	return fmt.Sprintf("trace %d: %v", e.Trace+1, e.Err)
+}
+
+func (e TraceReadError) Unwrap() error {
+	return e.Err
+}
+
+type UnknownOpcodeError struct {
+	PC     uint16
+	Opcode uint16
+}
+
+func (e UnknownOpcodeError) Error() string {
+	return fmt.Sprintf("unknown opcode 0x%04X at 0x%03X", e.Opcode, e.PC)
+}
+
+type StackOverflowError struct {
+	PC uint16
+}
+
+func (e StackOverflowError) Error() string {
+	return fmt.Sprintf("stack overflow at 0x%03X (%d nested calls)", e.PC, stackSize)
+}
+
+type StackUnderflowError struct {
+	PC uint16
+}
+
+func (e StackUnderflowError) Error() string {
+	return fmt.Sprintf("stack underflow at 0x%03X (return without call)", e.PC)
+}
+
+type MemoryAccessError struct {
+	PC    uint16
+	Addr  uint16
+	Size  int
+	Write bool
+}
+
+func (e MemoryAccessError) Error() string {
+	access := "read"
+	if e.Write {
+		access = "write"
+	}
+	return fmt.Sprintf("%s of 0x%X out of memory (size 0x%X) at 0x%03X", access, e.Addr, e.Size, e.PC)
+}
+
+type UnknownErrorPolicyError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownErrorPolicyError) Error() string {
+	return "unknown error policy: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
+
+type ROMError struct {
+	File string
+	Err  error
+}
+
+func (e ROMError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e ROMError) Unwrap() error {
+	return e.Err
+}
+
+type EmptyROMError struct{}
+
+func (e EmptyROMError) Error() string {
+	return "the rom is empty"
+}
+
+type ROMTooLargeError struct {
+	Size    int
+	Max     int
+	Variant Variant
+}
+
+func (e ROMTooLargeError) Error() string {
+	return fmt.Sprintf("the rom is too large for %s: %d bytes (max %d)", e.Variant, e.Size, e.Max)
+}
+
+type TextROMError struct{}
+
+func (e TextROMError) Error() string {
+	return "the file is text, not a rom (assemble sources with chip8 asm)"
+}
+
+type InvalidLoadAddressError struct {
+	Addr uint16
+	Size int
+}
+
+func (e InvalidLoadAddressError) Error() string {
+	return fmt.Sprintf("invalid load address 0x%X (memory size 0x%X)", e.Addr, e.Size)
+}
+
+type ArchiveROMError struct {
+	Archive string
+	ROMs    []string
+}
+
+func (e ArchiveROMError) Error() string {
+	if len(e.ROMs) == 0 {
+		return "no rom in the archive"
+	}
+	return fmt.Sprintf("the archive holds several roms, pick one with %s:NAME: %s", e.Archive, strings.Join(e.ROMs, ", "))
+}
+
+type NotInArchiveError struct {
+	Archive string
+	Name    string
+}
+
+func (e NotInArchiveError) Error() string {
+	return fmt.Sprintf("%s is not in the archive", e.Name)
+}
+
+type InvalidCartridgeError struct {
+	Reason string
+}
+
+func (e InvalidCartridgeError) Error() string {
+	return "invalid Octo cartridge: " + e.Reason
+}
+
+type CartridgeSourceError struct {
+	Err error
+}
+
+func (e CartridgeSourceError) Error() string {
+	return "the source of the cartridge does not assemble, only the subset of the Octo syntax of chip8 asm is " +
+		"supported (no :macro, :calc, :next or :unpack): " + e.Err.Error()
+}
+
+func (e CartridgeSourceError) Unwrap() error {
+	return e.Err
+}
+
+type PaletteSizeError struct {
+	Size int
+}
+
+func (e PaletteSizeError) Error() string {
+	return fmt.Sprintf("a palette has 2 to 4 colors, not %d", e.Size)
+}
+
+type InvalidColorError struct {
+	Color string
+}
+
+func (e InvalidColorError) Error() string {
+	return fmt.Sprintf("invalid color %q, expected #RRGGBB", e.Color)
+}
+
+type UnknownKeypadLayoutError struct {
+	Name  string
+	Valid []string
+}
+
+func (e UnknownKeypadLayoutError) Error() string {
+	return "unknown keypad layout: " + e.Name + " (valid: " + strings.Join(e.Valid, ", ") + ")"
+}
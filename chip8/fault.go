@@ -0,0 +1,63 @@
+package chip8
+
+import "strings"
+
+// ErrorPolicy is what the machine does when an instruction fails: an unknown opcode, a stack overflow or underflow
+// or a memory access past the end of the memory.
+type ErrorPolicy int
+
+const (
+	PolicyHalt  ErrorPolicy = iota // stop the rom, the error is the break reason and shown as a message
+	PolicyLog                      // show the error as a message and continue with the next instruction
+	PolicyPanic                    // panic, for tests that must not run into errors
+)
+
+var errorPolicyNames = map[ErrorPolicy]string{
+	PolicyHalt:  "halt",
+	PolicyLog:   "log",
+	PolicyPanic: "panic",
+}
+
+func (p ErrorPolicy) String() string {
+	return errorPolicyNames[p]
+}
+
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	for policy, name := range errorPolicyNames {
+		if strings.EqualFold(name, s) {
+			return policy, nil
+		}
+	}
+	return PolicyHalt, UnknownErrorPolicyError{Name: s, Valid: ErrorPolicyNames()}
+}
+
+func ErrorPolicyNames() []string {
+	return []string{PolicyHalt.String(), PolicyLog.String(), PolicyPanic.String()}
+}
+
+func (c *Chip8) SetErrorPolicy(p ErrorPolicy) {
+	c.onError = p
+}
+
+// fail records the error of the instruction being executed, the instruction skips what it can not do and step
+// returns the first error.
+func (c *Chip8) fail(err error) {
+	if c.fault == nil {
+		c.fault = err
+	}
+}
+
+func (c *Chip8) handleError(err error) {
+	switch c.onError {
+	case PolicyLog:
+		c.report(err, "")
+		if e, ok := err.(MemoryAccessError); ok && e.Addr == e.PC {
+			c.breakExecution(err.Error()) // the pc left the memory, there is no instruction to continue with
+		}
+	case PolicyPanic:
+		panic(err)
+	default:
+		c.breakExecution(err.Error())
+		c.report(err, "")
+	}
+}
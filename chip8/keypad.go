@@ -0,0 +1,105 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+// KeypadLayout selects the keys of the host keyboard that press the keys of the hexadecimal keypad.
+type KeypadLayout int
+
+const (
+	KeypadQWERTY KeypadLayout = iota // the 4x4 block 1234/QWER/ASDF/ZXCV in the positions of the COSMAC VIP keypad
+	KeypadAZERTY                     // the same block on an AZERTY keyboard, &é"'/AZER/QSDF/WXCV
+	KeypadNumpad                     // the numeric keypad, 789//456*/123-/0.<Enter>+
+	KeypadHex                        // the keys 0-9 and a-f with the characters of the keypad keys
+)
+
+var keypadLayoutNames = map[KeypadLayout]string{
+	KeypadQWERTY: "qwerty",
+	KeypadAZERTY: "azerty",
+	KeypadNumpad: "numpad",
+	KeypadHex:    "hex",
+}
+
+// keypadPositions are the keys of the COSMAC VIP keypad row by row, the layouts list their keys in this order.
+var keypadPositions = [16]byte{
+	0x1, 0x2, 0x3, 0xC,
+	0x4, 0x5, 0x6, 0xD,
+	0x7, 0x8, 0x9, 0xE,
+	0xA, 0x0, 0xB, 0xF,
+}
+
+var keypadLayouts = map[KeypadLayout][16]string{
+	KeypadQWERTY: {
+		"1", "2", "3", "4",
+		"q", "w", "e", "r",
+		"a", "s", "d", "f",
+		"z", "x", "c", "v",
+	},
+	KeypadAZERTY: {
+		"&", "é", "\"", "'",
+		"a", "z", "e", "r",
+		"q", "s", "d", "f",
+		"w", "x", "c", "v",
+	},
+	KeypadNumpad: {
+		"7", "8", "9", "/",
+		"4", "5", "6", "*",
+		"1", "2", "3", "-",
+		"0", ".", "<Enter>", "+",
+	},
+	KeypadHex: {
+		"1", "2", "3", "c",
+		"4", "5", "6", "d",
+		"7", "8", "9", "e",
+		"a", "0", "b", "f",
+	},
+}
+
+func (l KeypadLayout) String() string {
+	return keypadLayoutNames[l]
+}
+
+func ParseKeypadLayout(s string) (KeypadLayout, error) {
+	for l, name := range keypadLayoutNames {
+		if strings.EqualFold(name, s) {
+			return l, nil
+		}
+	}
+	return KeypadQWERTY, UnknownKeypadLayoutError{Name: s, Valid: KeypadLayoutNames()}
+}
+
+func KeypadLayoutNames() []string {
+	return []string{KeypadQWERTY.String(), KeypadAZERTY.String(), KeypadNumpad.String(), KeypadHex.String()}
+}
+
+// Keys returns the host key of every keypad key, indexed by the keypad key.
+func (l KeypadLayout) Keys() [16]string {
+	var keys [16]string
+	for i, key := range keypadLayouts[l] {
+		keys[keypadPositions[i]] = key
+	}
+	return keys
+}
+
+// SetKeypadLayout sets the host keys of the keypad, it applies to the controls created after it.
+func (c *Chip8) SetKeypadLayout(l KeypadLayout) {
+	c.keypad = l
+}
+
+// KeypadKeys returns the host key of every keypad key for the keypad panel, indexed by the keypad key.
+func (c Chip8) KeypadKeys() []string {
+	keys := c.keypad.Keys()
+	return keys[:]
+}
+
+// keypadControls are the controls that press the keypad keys.
+func (c *Chip8) keypadControls(m map[string]emulator.Control) {
+	for key, host := range c.keypad.Keys() {
+		key := byte(key)
+		m[host] = emulator.NewControl(func() { c.sendKeyboardInterrupt(key) }, fmt.Sprintf("keypad %X", key))
+	}
+}
@@ -0,0 +1,150 @@
+package chip8
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultLoadAddress = 0x200 // programs written for the original system begin at memory location 512 (0x200)
+	ETILoadAddress     = 0x600 // programs for the ETI-660 begin at 0x600
+)
+
+// MemorySize is the memory of the variant in bytes, 4K and 64K on XO-CHIP.
+func (v Variant) MemorySize() int {
+	if v >= VariantXOChip {
+		return xoMemorySize
+	}
+	return memorySize
+}
+
+// MaxROMSize is the largest rom that fits in the memory of the variant when it is loaded at addr.
+func (v Variant) MaxROMSize(addr uint16) int {
+	return v.MemorySize() - int(addr)
+}
+
+// ValidateROM checks that the rom is not empty, fits in the memory of the variant when loaded at addr and does
+// not look like a text file, e.g. the source of a rom.
+func ValidateROM(rom []byte, v Variant, addr uint16) error {
+	if int(addr) >= v.MemorySize() {
+		return InvalidLoadAddressError{Addr: addr, Size: v.MemorySize()}
+	}
+	if len(rom) == 0 {
+		return EmptyROMError{}
+	}
+	if len(rom) > v.MaxROMSize(addr) {
+		return ROMTooLargeError{Size: len(rom), Max: v.MaxROMSize(addr), Variant: v}
+	}
+	if isText(rom) {
+		return TextROMError{}
+	}
+	return nil
+}
+
+// isText reports whether the data is printable utf-8 text with at least one line break, roms practically never are.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	lines := false
+	for _, r := range string(data) {
+		switch {
+		case r == '\n':
+			lines = true
+		case r == '\r' || r == '\t' || r == '\uFEFF':
+		case r < 0x20 || r == 0x7F:
+			return false
+		}
+	}
+	return lines
+}
+
+// ROMFile is a rom read from a rom file, a zip archive or an Octo cartridge.
+type ROMFile struct {
+	Name      string // the file, archive.zip:entry for a rom in an archive
+	Data      []byte
+	Cartridge *Cartridge        // settings of an Octo cartridge, nil for other roms
+	Labels    map[string]uint16 // labels of the source of a cartridge
+}
+
+// OpenROM reads a rom file, a zip archive or an Octo cartridge gif. A rom in an archive is named with
+// archive.zip:entry, the entry can be left out when the archive holds one rom. The rom is not validated.
+func OpenROM(file string) (*ROMFile, error) {
+	archive, entry := SplitArchivePath(file)
+	rf := &ROMFile{Name: file}
+	var err error
+	if IsArchive(file) {
+		if entry, rf.Data, err = readArchive(archive, entry); err != nil {
+			return nil, ROMError{File: archive, Err: err}
+		}
+		rf.Name = archive + ":" + entry
+	} else if rf.Data, err = ioutil.ReadFile(file); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(path.Ext(rf.Name), ".gif") {
+		cart, prog, err := readCartridge(rf.Data)
+		if err != nil {
+			return nil, ROMError{File: rf.Name, Err: err}
+		}
+		rf.Data, rf.Cartridge, rf.Labels = prog.ROM, cart, prog.Labels
+	}
+	return rf, nil
+}
+
+// ReadROM reads a rom file, a zip archive or an Octo cartridge and validates the rom for the variant and load
+// address, the settings of a cartridge are ignored.
+func ReadROM(file string, v Variant, addr uint16) ([]byte, error) {
+	rf, err := OpenROM(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateROM(rf.Data, v, addr); err != nil {
+		return nil, ROMError{File: rf.Name, Err: err}
+	}
+	return rf.Data, nil
+}
+
+// openROM reads a rom and validates it for the settings it runs with, the settings of the user changed by the rom
+// database and the settings of a cartridge. Only a valid rom stops the rom that runs and configures the machine.
+func (c *Chip8) openROM(file string) (*ROMFile, error) {
+	rf, err := OpenROM(file)
+	if err != nil {
+		return nil, err
+	}
+	s := c.defaults
+	info := c.lookupROM(rf.Data, &s)
+	if rf.Cartridge != nil {
+		o := rf.Cartridge.Options
+		s.variant = o.Variant()
+		s.quirks = o.Quirks()
+		if o.Speed() > 0 {
+			s.speed = o.Speed()
+		}
+		if p := o.Palette(); p != nil {
+			s.palette = p
+		}
+	}
+	if err := ValidateROM(rf.Data, s.variant, s.loadAddr); err != nil {
+		return nil, ROMError{File: rf.Name, Err: err}
+	}
+	c.halt()
+	c.variant, c.quirks, c.loadAddr, c.palette = s.variant, s.quirks, s.loadAddr, s.palette
+	c.setSpeed(s.speed)
+	c.romInfo, c.keyHints = info, c.keyHintsOf(info)
+	return rf, nil
+}
+
+// HashROM is the sha1 of the rom in hexadecimal, it identifies the rom in save states and the rom database.
+func HashROM(rom []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(rom))
+}
+
+// SetLoadAddress sets the address roms are loaded at and start executing from, it applies to the next rom loaded.
+func (c *Chip8) SetLoadAddress(addr uint16) {
+	c.loadAddr = addr
+	c.defaults.loadAddr = addr
+}
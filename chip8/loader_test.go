@@ -0,0 +1,55 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestValidateROM(t *testing.T) {
+	for _, tc := range []struct {
+		v   Variant
+		max int // largest rom at 0x200
+	}{
+		{VariantChip8, 3584},
+		{VariantSChip, 3584},
+		{VariantXOChip, 65024},
+	} {
+		for _, rc := range []struct {
+			name string
+			rom  []byte
+			addr uint16
+			err  error
+		}{
+			{"empty", nil, DefaultLoadAddress, EmptyROMError{}},
+			{"largest", make([]byte, tc.max), DefaultLoadAddress, nil},
+			{"too large", make([]byte, tc.max+1), DefaultLoadAddress, ROMTooLargeError{Size: tc.max + 1, Max: tc.max, Variant: tc.v}},
+			{"largest at 600", make([]byte, tc.max-0x400), ETILoadAddress, nil},
+			{"too large at 600", make([]byte, tc.max-0x3FF), ETILoadAddress, ROMTooLargeError{Size: tc.max - 0x3FF, Max: tc.max - 0x400, Variant: tc.v}},
+			{"load address past the memory", []byte{0x12, 0x00}, uint16(tc.v.MemorySize()), InvalidLoadAddressError{Addr: uint16(tc.v.MemorySize()), Size: tc.v.MemorySize()}},
+			{"source", []byte(": main\r\n\tclear\n\tjump main\n"), DefaultLoadAddress, TextROMError{}},
+			{"source with a bom", []byte("\uFEFFclear\n"), DefaultLoadAddress, TextROMError{}},
+			{"printable rom", []byte("AAAA"), DefaultLoadAddress, nil},
+			{"rom with a line feed", []byte{0x6A, 0x0A, 0x12, 0x00}, DefaultLoadAddress, nil},
+			{"invalid utf-8", []byte{0xFF, 0x0A, 0x41}, DefaultLoadAddress, nil},
+		} {
+			if tc.v == VariantXOChip && rc.addr == uint16(tc.v.MemorySize()) {
+				continue // 0x10000 does not fit in an address
+			}
+			if err := ValidateROM(rc.rom, tc.v, rc.addr); err != rc.err {
+				t.Errorf("%s: %s: %v, want %v", tc.v, rc.name, err, rc.err)
+			}
+		}
+	}
+}
+
+func TestReadROMNamesFile(t *testing.T) {
+	file := writeROM(t, "big.ch8", bytes.Repeat([]byte{0x12, 0x00}, 2000))
+	_, err := ReadROM(file, VariantChip8, DefaultLoadAddress)
+	if want := fmt.Sprintf("%s: %s", file, ROMTooLargeError{Size: 4000, Max: 3584, Variant: VariantChip8}); err == nil || err.Error() != want {
+		t.Errorf("%v, want %s", err, want)
+	}
+	if _, err := ReadROM(file, VariantXOChip, DefaultLoadAddress); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,50 @@
+package chip8
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParsePalette parses the #RRGGBB or #RGB colors of the bitplane combinations, background first. Combinations
+// without a color get the last one.
+func ParsePalette(colors []string) (color.Palette, error) {
+	if len(colors) < 2 || len(colors) > 4 {
+		return nil, PaletteSizeError{Size: len(colors)}
+	}
+	var p color.Palette
+	for i := 0; i < 4; i++ {
+		s := colors[len(colors)-1]
+		if i < len(colors) {
+			s = colors[i]
+		}
+		c, ok := parseColor(s)
+		if !ok {
+			return nil, InvalidColorError{Color: s}
+		}
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+// parseColor parses a #RRGGBB or #RGB color.
+func parseColor(s string) (color.RGBA, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, false
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xFF}, true
+}
+
+// SetPalette sets the colors roms are shown with when neither their cartridge nor the rom database sets them, nil
+// keeps the colors of the TUI. It applies to the next rom loaded.
+func (c *Chip8) SetPalette(p color.Palette) {
+	c.defaults.palette = p
+}
@@ -0,0 +1,140 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexIncrement describes how FX55 and FX65 change I after the registers are transferred.
+type IndexIncrement int
+
+const (
+	IndexIncrementX1 IndexIncrement = iota // I is increased by X + 1 (COSMAC VIP)
+	IndexIncrementX                        // I is increased by X (CHIP-48)
+	IndexUnchanged                         // I is left unmodified (SUPER-CHIP)
+)
+
+// Quirks selects the behavior of opcodes that differ between CHIP-8 interpreters.
+type Quirks struct {
+	VFReset     bool           `json:"vfReset"`     // 8XY1, 8XY2 and 8XY3 reset VF to 0
+	Shift       bool           `json:"shift"`       // 8XY6 and 8XYE shift VX in place instead of shifting VY into VX
+	Jump        bool           `json:"jump"`        // BNNN jumps to XNN plus VX instead of NNN plus V0
+	Clip        bool           `json:"clip"`        // DXYN clips sprites at the screen edges instead of wrapping them around
+	LoadStore   IndexIncrement `json:"loadStore"`   // how FX55 and FX65 change I
+	KeyRelease  bool           `json:"keyRelease"`  // FX0A completes when the key is released instead of when it is pressed
+	DisplayWait bool           `json:"displayWait"` // DXYN waits for the next frame before the next instruction runs
+}
+
+const DefaultQuirks = "modern"
+
+// QuirksPresets are the quirks of the interpreters roms are usually written for.
+// modern matches what Octo and most roms written today expect.
+var QuirksPresets = map[string]Quirks{
+	"vip":    {VFReset: true, Clip: true, LoadStore: IndexIncrementX1, KeyRelease: true, DisplayWait: true},
+	"chip48": {Shift: true, Jump: true, Clip: true, LoadStore: IndexIncrementX},
+	"schip":  {Shift: true, Jump: true, Clip: true, LoadStore: IndexUnchanged},
+	"modern": {LoadStore: IndexIncrementX1},
+}
+
+// ParseQuirks parses a preset name followed by comma separated overrides,
+// e.g. "schip,jump=false,loadstore=x1".
+func ParseQuirks(s string) (Quirks, error) {
+	fields := strings.Split(s, ",")
+	q, ok := QuirksPresets[strings.ToLower(strings.TrimSpace(fields[0]))]
+	if !ok {
+		return Quirks{}, UnknownQuirksPresetError{Name: fields[0], Valid: QuirksPresetNames()}
+	}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := "true"
+		if len(kv) == 2 {
+			value = strings.ToLower(strings.TrimSpace(kv[1]))
+		}
+		if err := q.set(name, value); err != nil {
+			return Quirks{}, err
+		}
+	}
+	return q, nil
+}
+
+func (q *Quirks) set(name, value string) error {
+	if name == "loadstore" {
+		switch value {
+		case "x1":
+			q.LoadStore = IndexIncrementX1
+		case "x":
+			q.LoadStore = IndexIncrementX
+		case "unchanged":
+			q.LoadStore = IndexUnchanged
+		default:
+			return InvalidQuirkValueError{Name: name, Value: value}
+		}
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return InvalidQuirkValueError{Name: name, Value: value}
+	}
+	switch name {
+	case "vfreset":
+		q.VFReset = b
+	case "shift":
+		q.Shift = b
+	case "jump":
+		q.Jump = b
+	case "clip":
+		q.Clip = b
+	case "keyrelease":
+		q.KeyRelease = b
+	case "displaywait":
+		q.DisplayWait = b
+	default:
+		return UnknownQuirkError{Name: name, Valid: []string{"vfreset", "shift", "jump", "clip", "loadstore", "keyrelease", "displaywait"}}
+	}
+	return nil
+}
+
+func QuirksPresetNames() []string {
+	var names []string
+	for k := range QuirksPresets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var indexIncrementNames = map[IndexIncrement]string{
+	IndexIncrementX1: "x1",
+	IndexIncrementX:  "x",
+	IndexUnchanged:   "unchanged",
+}
+
+// String formats the quirks the way ParseQuirks reads them, the name of a preset or the modern preset with the
+// quirks that differ from it.
+func (q Quirks) String() string {
+	for _, name := range QuirksPresetNames() {
+		if QuirksPresets[name] == q {
+			return name
+		}
+	}
+	m := QuirksPresets[DefaultQuirks]
+	s := DefaultQuirks
+	for _, f := range []struct {
+		name string
+		q, m bool
+	}{
+		{"vfreset", q.VFReset, m.VFReset}, {"shift", q.Shift, m.Shift}, {"jump", q.Jump, m.Jump},
+		{"clip", q.Clip, m.Clip}, {"keyrelease", q.KeyRelease, m.KeyRelease}, {"displaywait", q.DisplayWait, m.DisplayWait},
+	} {
+		if f.q != f.m {
+			s += fmt.Sprintf(",%s=%t", f.name, f.q)
+		}
+	}
+	if q.LoadStore != m.LoadStore {
+		s += ",loadstore=" + indexIncrementNames[q.LoadStore]
+	}
+	return s
+}
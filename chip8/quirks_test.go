@@ -0,0 +1,217 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuirkVFReset(t *testing.T) {
+	for _, tc := range []struct {
+		opcode byte // low byte of 8XY_
+		v1, vf byte
+	}{
+		{0x11, 0xFF, 0}, {0x12, 0x00, 0}, {0x13, 0xFF, 0},
+	} {
+		for _, reset := range []bool{false, true} {
+			c := newTestChip(t, Quirks{VFReset: reset}, 0x81, 0x20|tc.opcode&0xF)
+			c.v[1], c.v[2], c.v[0xF] = 0x0F, 0xF0, 5
+			mustStep(t, c)
+			wantVF := byte(5)
+			if reset {
+				wantVF = tc.vf
+			}
+			if c.v[1] != tc.v1 || c.v[0xF] != wantVF {
+				t.Errorf("81 2%X vfreset=%t: V1=%02X VF=%d, want V1=%02X VF=%d", tc.opcode&0xF, reset, c.v[1], c.v[0xF], tc.v1, wantVF)
+			}
+		}
+	}
+}
+
+func TestQuirkShift(t *testing.T) {
+	for _, tc := range []struct {
+		opcode byte
+		shift  bool
+		v1, vf byte
+	}{
+		{0x26, false, 0x40, 1}, // V1 = V2 >> 1
+		{0x26, true, 0x02, 0},  // V1 = V1 >> 1
+		{0x2E, false, 0x02, 1}, // V1 = V2 << 1
+		{0x2E, true, 0x08, 0},  // V1 = V1 << 1
+	} {
+		c := newTestChip(t, Quirks{Shift: tc.shift}, 0x81, tc.opcode)
+		c.v[1], c.v[2] = 0x04, 0x81
+		mustStep(t, c)
+		if c.v[1] != tc.v1 || c.v[0xF] != tc.vf {
+			t.Errorf("81 %02X shift=%t: V1=%02X VF=%d, want V1=%02X VF=%d", tc.opcode, tc.shift, c.v[1], c.v[0xF], tc.v1, tc.vf)
+		}
+		if c.v[2] != 0x81 {
+			t.Errorf("81 %02X shift=%t changed V2 to %02X", tc.opcode, tc.shift, c.v[2])
+		}
+	}
+}
+
+func TestQuirkJump(t *testing.T) {
+	for _, tc := range []struct {
+		jump bool
+		pc   uint16
+	}{
+		{false, 0x212}, // NNN + V0
+		{true, 0x214},  // NNN + V2
+	} {
+		c := newTestChip(t, Quirks{Jump: tc.jump}, 0xB2, 0x10)
+		c.v[0], c.v[2] = 2, 4
+		mustStep(t, c)
+		if c.pc != tc.pc {
+			t.Errorf("B210 jump=%t: PC=%03X, want %03X", tc.jump, c.pc, tc.pc)
+		}
+	}
+}
+
+func TestQuirkClip(t *testing.T) {
+	for _, clip := range []bool{false, true} {
+		// a row of 8 pixels at x 60 and a column of 2 pixels at y 31
+		c := newTestChip(t, Quirks{Clip: clip}, 0xD0, 0x11, 0xA3, 0x01, 0xD2, 0x32)
+		c.memory[0x300], c.memory[0x301], c.memory[0x302] = 0xFF, 0x80, 0x80
+		c.i = 0x300
+		c.v[0], c.v[1] = 60, 0
+		c.v[2], c.v[3] = 10, 31
+		mustStep(t, c)
+		mustStep(t, c)
+		mustStep(t, c)
+		if c.screenBuf[63] == 0 || c.screenBuf[10+31*screenWidth] == 0 {
+			t.Errorf("clip=%t: the pixels inside the screen are not drawn", clip)
+		}
+		wrapped := c.screenBuf[0] != 0 && c.screenBuf[3] != 0 && c.screenBuf[10] != 0
+		if wrapped == clip {
+			t.Errorf("clip=%t: pixels past the edges wrapped=%t", clip, wrapped)
+		}
+		if c.v[0xF] != 0 {
+			t.Errorf("clip=%t: VF=%d without a collision", clip, c.v[0xF])
+		}
+	}
+}
+
+func TestQuirkLoadStore(t *testing.T) {
+	for _, tc := range []struct {
+		mode IndexIncrement
+		i    uint16
+	}{
+		{IndexIncrementX1, 0x303},
+		{IndexIncrementX, 0x302},
+		{IndexUnchanged, 0x300},
+	} {
+		c := newTestChip(t, Quirks{LoadStore: tc.mode}, 0xF2, 0x55)
+		c.i = 0x300
+		c.v[0], c.v[1], c.v[2], c.v[3] = 1, 2, 3, 4
+		mustStep(t, c)
+		if got := c.memory[0x300:0x304]; got[0] != 1 || got[1] != 2 || got[2] != 3 || got[3] != 0 {
+			t.Errorf("F255 loadstore=%s: memory % X, want 01 02 03 00", indexIncrementNames[tc.mode], got)
+		}
+		if c.i != tc.i {
+			t.Errorf("F255 loadstore=%s: I=%03X, want %03X", indexIncrementNames[tc.mode], c.i, tc.i)
+		}
+
+		c = newTestChip(t, Quirks{LoadStore: tc.mode}, 0xF2, 0x65)
+		c.i = 0x300
+		copy(c.memory[0x300:], []byte{5, 6, 7, 8})
+		mustStep(t, c)
+		if c.v[0] != 5 || c.v[1] != 6 || c.v[2] != 7 || c.v[3] != 0 {
+			t.Errorf("F265 loadstore=%s: V0-V3 % X, want 05 06 07 00", indexIncrementNames[tc.mode], c.v[:4])
+		}
+		if c.i != tc.i {
+			t.Errorf("F265 loadstore=%s: I=%03X, want %03X", indexIncrementNames[tc.mode], c.i, tc.i)
+		}
+	}
+}
+
+func TestQuirkKeyRelease(t *testing.T) {
+	for _, release := range []bool{false, true} {
+		c := newTestChip(t, Quirks{KeyRelease: release}, 0xF3, 0x0A)
+		mustStep(t, c)
+		c.pressKey(7)
+		if got := c.waitingKey; got != release {
+			t.Errorf("keyrelease=%t: waiting after the press=%t", release, got)
+		}
+		c.clearKeys()
+		if c.waitingKey || c.v[3] != 7 {
+			t.Errorf("keyrelease=%t: waiting=%t V3=%X after the release, want V3=7", release, c.waitingKey, c.v[3])
+		}
+	}
+}
+
+func TestQuirkDisplayWait(t *testing.T) {
+	for _, tc := range []struct {
+		wait   bool
+		cycles uint64
+	}{
+		{false, 10},
+		{true, 1},
+	} {
+		rom := make([]byte, 0, 20)
+		for i := 0; i < 10; i++ {
+			rom = append(rom, 0xD0, 0x01)
+		}
+		c := newTestChip(t, Quirks{DisplayWait: tc.wait}, rom...)
+		c.SetSpeed(10 * timerHz)
+		c.emulateFrame()
+		if c.cycle != tc.cycles {
+			t.Errorf("displaywait=%t: %d instructions in a frame, want %d", tc.wait, c.cycle, tc.cycles)
+		}
+	}
+}
+
+func TestParseQuirks(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want Quirks
+	}{
+		{"modern", Quirks{LoadStore: IndexIncrementX1}},
+		{"VIP", QuirksPresets["vip"]},
+		{"schip,jump=false,loadstore=x1", Quirks{Shift: true, Clip: true, LoadStore: IndexIncrementX1}},
+		{"modern, vfreset, displaywait=1", Quirks{VFReset: true, LoadStore: IndexIncrementX1, DisplayWait: true}},
+	} {
+		q, err := ParseQuirks(tc.s)
+		if err != nil {
+			t.Errorf("ParseQuirks(%q): %v", tc.s, err)
+			continue
+		}
+		if q != tc.want {
+			t.Errorf("ParseQuirks(%q) = %+v, want %+v", tc.s, q, tc.want)
+		}
+	}
+	if _, err := ParseQuirks("octo"); !errors.As(err, new(UnknownQuirksPresetError)) {
+		t.Errorf("ParseQuirks(octo): %v, want UnknownQuirksPresetError", err)
+	}
+	if _, err := ParseQuirks("modern,wrap"); !errors.As(err, new(UnknownQuirkError)) {
+		t.Errorf("ParseQuirks(modern,wrap): %v, want UnknownQuirkError", err)
+	}
+	for _, s := range []string{"modern,clip=maybe", "modern,loadstore=x2"} {
+		if _, err := ParseQuirks(s); !errors.As(err, new(InvalidQuirkValueError)) {
+			t.Errorf("ParseQuirks(%s): %v, want InvalidQuirkValueError", s, err)
+		}
+	}
+}
+
+func TestQuirksStringRoundTrip(t *testing.T) {
+	quirks := []Quirks{
+		{Shift: true, LoadStore: IndexUnchanged},
+		{VFReset: true, Jump: true, KeyRelease: true, LoadStore: IndexIncrementX},
+		{Clip: true, DisplayWait: true},
+	}
+	for _, name := range QuirksPresetNames() {
+		if s := QuirksPresets[name].String(); s != name {
+			t.Errorf("preset %s formats as %q", name, s)
+		}
+		quirks = append(quirks, QuirksPresets[name])
+	}
+	for _, q := range quirks {
+		got, err := ParseQuirks(q.String())
+		if err != nil {
+			t.Errorf("ParseQuirks(%q): %v", q.String(), err)
+			continue
+		}
+		if got != q {
+			t.Errorf("ParseQuirks(%q) = %+v, want %+v", q.String(), got, q)
+		}
+	}
+}
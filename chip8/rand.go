@@ -0,0 +1,20 @@
+package chip8
+
+// rng is a xorshift64* generator, unlike math/rand its whole state is a single number that is saved with the machine.
+type rng struct {
+	state uint64
+}
+
+func (r *rng) seed(s uint64) {
+	if s == 0 {
+		s = 0x9E3779B97F4A7C15 // xorshift is stuck at 0
+	}
+	r.state = s
+}
+
+func (r *rng) next() byte {
+	r.state ^= r.state >> 12
+	r.state ^= r.state << 25
+	r.state ^= r.state >> 27
+	return byte((r.state * 0x2545F4914F6CDD1D) >> 56)
+}
@@ -0,0 +1,223 @@
+package chip8
+
+import "unsafe"
+
+const (
+	// DefaultRewindSize is the number of instructions kept in the rewind buffer, about 14 seconds at the default speed.
+	DefaultRewindSize = 10000
+	// DefaultRewindMemory is the number of bytes the memory and pixel changes in the rewind buffer may use, a clear
+	// of the 128x64 screen changes up to 8192 pixels.
+	DefaultRewindMemory = 16 << 20
+
+	changeSize = int(unsafe.Sizeof(change{}))
+	// maxKeptChanges is the capacity of the changes a record keeps when it is reused, larger ones are freed so a
+	// few instructions that changed a lot do not hold on to their memory.
+	maxKeptChanges = 64
+)
+
+// undo holds everything needed to revert one instruction: a copy of the cpu before it ran and the old values
+// of the memory and pixels it changed.
+type undo struct {
+	cpu        cpu
+	memory     []change
+	pixels     []change
+	frameStart bool // first instruction of a frame, rewinding a frame stops after reverting it
+}
+
+type change struct {
+	addr uint16
+	old  byte
+}
+
+// history is a ring buffer with the undo records of the last instructions, the oldest record is overwritten
+// when it is full and the oldest records are dropped when their changes use more than the memory of the buffer.
+type history struct {
+	records    []undo
+	start      int
+	n          int
+	changes    int // memory and pixel changes of the recorded instructions
+	maxChanges int
+	current    *undo // record of the instruction being executed, nil when nothing is recorded
+	newFrame   bool
+}
+
+func (h *history) resize(size int, memory int) {
+	*h = history{maxChanges: memory / changeSize}
+	if size > 0 {
+		h.records = make([]undo, size)
+	}
+}
+
+func (h *history) clear() {
+	for i := range h.records {
+		h.records[i].release()
+	}
+	h.start, h.n, h.changes = 0, 0, 0
+	h.current = nil
+}
+
+// release empties the changes of a record, the memory of large ones is freed.
+func (r *undo) release() {
+	if cap(r.memory) > maxKeptChanges {
+		r.memory = nil
+	}
+	if cap(r.pixels) > maxKeptChanges {
+		r.pixels = nil
+	}
+	r.memory = r.memory[:0]
+	r.pixels = r.pixels[:0]
+}
+
+func (r *undo) changes() int {
+	return len(r.memory) + len(r.pixels)
+}
+
+// begin starts the record of an instruction, the record of the oldest instruction is reused when the buffer is full.
+func (h *history) begin(c cpu) {
+	if len(h.records) == 0 {
+		return
+	}
+	index := (h.start + h.n) % len(h.records)
+	r := &h.records[index]
+	if h.n == len(h.records) {
+		h.start = (h.start + 1) % len(h.records)
+		h.changes -= r.changes()
+	} else {
+		h.n++
+	}
+	r.cpu = c
+	r.release()
+	r.frameStart = h.newFrame
+	h.newFrame = false
+	h.current = r
+}
+
+// end finishes the record of an instruction, the oldest records are dropped until the changes fit in the memory
+// of the buffer. The last record is always kept.
+func (h *history) end() {
+	if h.current == nil {
+		return
+	}
+	h.changes += h.current.changes()
+	h.current = nil
+	for h.changes > h.maxChanges && h.n > 1 {
+		r := &h.records[h.start]
+		h.changes -= r.changes()
+		r.release()
+		h.start = (h.start + 1) % len(h.records)
+		h.n--
+	}
+}
+
+// pop removes the record of the last instruction.
+func (h *history) pop() (*undo, bool) {
+	if h.n == 0 {
+		return nil, false
+	}
+	h.n--
+	r := &h.records[(h.start+h.n)%len(h.records)]
+	h.changes -= r.changes()
+	return r, true
+}
+
+// SetRewindSize sets the number of instructions that can be rewound, 0 disables recording. The recorded
+// instructions are dropped, it is only safe to call while the rom is stopped.
+func (c *Chip8) SetRewindSize(size int) {
+	c.history.resize(size, c.history.maxChanges*changeSize)
+}
+
+// SetRewindMemory sets the number of bytes the memory and pixel changes of the recorded instructions may use, fewer
+// instructions can be rewound when they change a lot. The recorded instructions are dropped, it is only safe to
+// call while the rom is stopped.
+func (c *Chip8) SetRewindMemory(bytes int) {
+	c.history.resize(len(c.history.records), bytes)
+}
+
+// write stores b in memory, the old value is recorded so the instruction can be rewound, watchpoints are checked and
+// the write is traced. Writing past the memory fails the instruction.
+func (c *Chip8) write(addr uint16, b byte) {
+	if int(addr) >= c.memSize {
+		c.fail(MemoryAccessError{PC: c.execAddr, Addr: addr, Size: c.memSize, Write: true})
+		return
+	}
+	c.debug.access(addr, WatchWrite)
+	if r := c.history.current; r != nil {
+		r.memory = append(r.memory, change{addr: addr, old: c.memory[addr]})
+	}
+	if c.trace.active {
+		c.trace.writes = append(c.trace.writes, MemWrite{Addr: addr, Value: b})
+	}
+	c.memory[addr] = b
+}
+
+// setPixel sets the planes of a pixel, the old value is recorded so the instruction can be rewound.
+func (c *Chip8) setPixel(index int, p byte) {
+	if c.screenBuf[index] == p {
+		return
+	}
+	if r := c.history.current; r != nil {
+		r.pixels = append(r.pixels, change{addr: uint16(index), old: c.screenBuf[index]})
+	}
+	c.screenBuf[index] = p
+}
+
+// undo reverts the last recorded instruction, it reports whether there was one and if it started a frame.
+func (c *Chip8) undo() (ok bool, frameStart bool) {
+	r, ok := c.history.pop()
+	if !ok {
+		return false, false
+	}
+	for i := len(r.pixels) - 1; i >= 0; i-- {
+		c.screenBuf[r.pixels[i].addr] = r.pixels[i].old
+	}
+	for i := len(r.memory) - 1; i >= 0; i-- {
+		c.memory[r.memory[i].addr] = r.memory[i].old
+	}
+	for i := range c.v {
+		c.vChanged[i] = c.v[i] != r.cpu.v[i]
+	}
+	c.cpu = r.cpu
+	c.drawFlag = true
+	return true, r.frameStart
+}
+
+// StepBack reverts the last instruction while the rom is stopped.
+func (c *Chip8) StepBack() {
+	if c.isRunning() {
+		return
+	}
+	if ok, _ := c.undo(); ok {
+		c.stepCycles = 0
+		c.publishKeys()
+	}
+	c.publish()
+}
+
+// RewindFrame reverts the instructions of the last frame while the rom is stopped.
+func (c *Chip8) RewindFrame() {
+	if c.isRunning() {
+		return
+	}
+	rewound := false
+	for {
+		ok, frameStart := c.undo()
+		if !ok {
+			break
+		}
+		rewound = true
+		if frameStart {
+			break
+		}
+	}
+	if rewound {
+		c.stepCycles = 0
+		c.publishKeys()
+	}
+	c.publish()
+}
+
+// rewind stops the rom and goes back one frame, holding the key runs the rom backwards.
+func (c *Chip8) rewind() {
+	c.halt()
+	c.RewindFrame()
+}
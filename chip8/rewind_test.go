@@ -0,0 +1,60 @@
+package chip8
+
+import "testing"
+
+func TestStepBack(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x60, 0x05, 0xA3, 0x00, 0xF0, 0x55, 0xD0, 0x05, 0x12, 0x08)
+	for i := 0; i < 4; i++ {
+		mustStep(t, c)
+	}
+	for i := 0; i < 4; i++ {
+		c.StepBack()
+	}
+	if c.pc != 0x200 || c.v[0] != 0 || c.i != 0 || c.memory[0x300] != 0 {
+		t.Errorf("after stepping back PC=%03X V0=%d I=%03X [300]=%d, want the power on state", c.pc, c.v[0], c.i, c.memory[0x300])
+	}
+	for _, p := range c.screenBuf {
+		if p != 0 {
+			t.Fatal("stepping back left pixels of the sprite on the screen")
+		}
+	}
+}
+
+// TestRewindMemory records saves of 16 registers in a loop, the oldest instructions are dropped to stay within the
+// memory of the rewind buffer.
+func TestRewindMemory(t *testing.T) {
+	c := newTestChip(t, Quirks{LoadStore: IndexUnchanged}, 0xA3, 0x00, 0xFF, 0x55, 0x12, 0x02)
+	c.SetRewindMemory(100 * changeSize)
+	for i := 0; i < 50; i++ {
+		mustStep(t, c)
+	}
+	// every other instruction is the jump back, which changes nothing
+	if c.history.changes > 100 || c.history.changes < 100-16 || c.history.n > 12 {
+		t.Errorf("%d instructions with %d changes recorded, want 6 saves of 16 bytes and their jumps", c.history.n, c.history.changes)
+	}
+	for c.history.n > 0 {
+		c.StepBack()
+	}
+	if c.history.changes != 0 {
+		t.Errorf("%d changes left in the empty buffer", c.history.changes)
+	}
+}
+
+// TestRewindReleasesLargeRecords checks that a record of an instruction that changed many pixels does not keep its
+// memory when it is reused.
+func TestRewindReleasesLargeRecords(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x00, 0xE0, 0x12, 0x00)
+	c.SetRewindSize(2)
+	for i := range c.screenBuf[:screenWidth*screenHeigth] {
+		c.screenBuf[i] = 1
+	}
+	mustStep(t, c)
+	if got := len(c.history.records[0].pixels); got != screenWidth*screenHeigth {
+		t.Fatalf("00E0 recorded %d pixels, want %d", got, screenWidth*screenHeigth)
+	}
+	mustStep(t, c)
+	mustStep(t, c)
+	if got := cap(c.history.records[0].pixels); got > maxKeptChanges {
+		t.Errorf("the reused record keeps room for %d pixels", got)
+	}
+}
@@ -0,0 +1,288 @@
+package chip8
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// The bundled database is a copy of the programs and platforms of the community CHIP-8 database
+// (https://github.com/chip-8/chip-8-database), make romdb updates the programs.
+var (
+	//go:embed romdb/programs.json
+	bundledPrograms []byte
+	//go:embed romdb/platforms.json
+	bundledPlatforms []byte
+)
+
+// ROMDatabase finds the programs roms belong to by the sha1 of the rom.
+type ROMDatabase struct {
+	roms      map[string]ROMInfo
+	platforms map[string]Platform
+}
+
+// Program is a program of the database, it can have several roms, e.g. versions for different platforms.
+type Program struct {
+	Title   string              `json:"title"`
+	Authors []string            `json:"authors"`
+	ROMs    map[string]ROMEntry `json:"roms"` // by sha1
+}
+
+// ROMEntry describes how a rom runs, every field is optional.
+type ROMEntry struct {
+	File            string                    `json:"file"`
+	Platforms       []string                  `json:"platforms"` // the platforms the rom runs on, the preferred one first
+	QuirkyPlatforms map[string]PlatformQuirks `json:"quirkyPlatforms"`
+	TickRate        int                       `json:"tickrate"` // instructions per frame
+	StartAddress    int                       `json:"startAddress"`
+	Keys            map[string]int            `json:"keys"` // the keys the rom uses, "up": 5
+	Colors          struct {
+		Pixels []string `json:"pixels"` // #RRGGBB of each bitplane combination
+	} `json:"colors"`
+}
+
+// Platform is an interpreter roms are written for.
+type Platform struct {
+	ID              string         `json:"id"`
+	Name            string         `json:"name"`
+	DefaultTickRate int            `json:"defaultTickrate"`
+	Quirks          PlatformQuirks `json:"quirks"`
+}
+
+// PlatformQuirks are the quirks of a platform, a nil quirk of a rom keeps the quirk of the platform.
+type PlatformQuirks struct {
+	Shift                 *bool `json:"shift"`
+	MemoryIncrementByX    *bool `json:"memoryIncrementByX"`
+	MemoryLeaveIUnchanged *bool `json:"memoryLeaveIUnchanged"`
+	Wrap                  *bool `json:"wrap"`
+	Jump                  *bool `json:"jump"`
+	VBlank                *bool `json:"vblank"`
+	Logic                 *bool `json:"logic"`
+}
+
+// ROMInfo is a rom found in the database with the program it belongs to.
+type ROMInfo struct {
+	Program
+	ROMEntry
+}
+
+// platformVariants are the variants that run the platforms, platforms that are missing are not supported.
+var platformVariants = map[string]Variant{
+	"originalChip8": VariantChip8,
+	"hybridVIP":     VariantChip8,
+	"modernChip8":   VariantChip8,
+	"chip48":        VariantChip8,
+	"superchip1":    VariantSChip,
+	"superchip":     VariantSChip,
+	"xochip":        VariantXOChip,
+}
+
+// NewROMDatabase returns the bundled database.
+func NewROMDatabase() (*ROMDatabase, error) {
+	db := &ROMDatabase{roms: make(map[string]ROMInfo), platforms: make(map[string]Platform)}
+	var platforms []Platform
+	if err := json.Unmarshal(bundledPlatforms, &platforms); err != nil {
+		return nil, err
+	}
+	for _, p := range platforms {
+		db.platforms[p.ID] = p
+	}
+	if err := db.AddPrograms(bytes.NewReader(bundledPrograms)); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// AddPrograms adds the programs of a programs.json file to the database, they replace the roms already in it.
+func (db *ROMDatabase) AddPrograms(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var programs []Program
+	if err := json.Unmarshal(data, &programs); err != nil {
+		return err
+	}
+	for _, p := range programs {
+		for hash, rom := range p.ROMs {
+			db.roms[strings.ToLower(hash)] = ROMInfo{Program: p, ROMEntry: rom}
+		}
+	}
+	return nil
+}
+
+// ReadPrograms adds the programs of a programs.json file to the database.
+func (db *ROMDatabase) ReadPrograms(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := db.AddPrograms(f); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// Lookup finds a rom by its sha1 in hexadecimal, a nil database has no roms.
+func (db *ROMDatabase) Lookup(hash string) (ROMInfo, bool) {
+	if db == nil {
+		return ROMInfo{}, false
+	}
+	info, ok := db.roms[strings.ToLower(hash)]
+	return info, ok
+}
+
+// Name is the title and the authors of the program.
+func (r ROMInfo) Name() string {
+	if len(r.Authors) == 0 {
+		return r.Title
+	}
+	return fmt.Sprintf("%s by %s", r.Title, strings.Join(r.Authors, ", "))
+}
+
+// Platform is the first platform of the rom the emulator supports.
+func (db *ROMDatabase) Platform(r ROMInfo) (Platform, bool) {
+	for _, id := range r.Platforms {
+		if _, ok := platformVariants[id]; !ok {
+			continue
+		}
+		if p, ok := db.platforms[id]; ok {
+			return p, true
+		}
+	}
+	return Platform{}, false
+}
+
+// Quirks are the quirks of the platform with the quirks the rom changes on it.
+func (r ROMInfo) Quirks(p Platform) Quirks {
+	pq := p.Quirks
+	pq.override(r.QuirkyPlatforms[p.ID])
+	q := Quirks{
+		VFReset:     isSet(pq.Logic),
+		Shift:       isSet(pq.Shift),
+		Jump:        isSet(pq.Jump),
+		Clip:        !isSet(pq.Wrap),
+		LoadStore:   IndexIncrementX1,
+		KeyRelease:  p.ID == "originalChip8" || p.ID == "hybridVIP",
+		DisplayWait: isSet(pq.VBlank),
+	}
+	switch {
+	case isSet(pq.MemoryLeaveIUnchanged):
+		q.LoadStore = IndexUnchanged
+	case isSet(pq.MemoryIncrementByX):
+		q.LoadStore = IndexIncrementX
+	}
+	return q
+}
+
+func (q *PlatformQuirks) override(o PlatformQuirks) {
+	for _, f := range []struct{ q, o **bool }{
+		{&q.Shift, &o.Shift}, {&q.MemoryIncrementByX, &o.MemoryIncrementByX},
+		{&q.MemoryLeaveIUnchanged, &o.MemoryLeaveIUnchanged}, {&q.Wrap, &o.Wrap}, {&q.Jump, &o.Jump},
+		{&q.VBlank, &o.VBlank}, {&q.Logic, &o.Logic},
+	} {
+		if *f.o != nil {
+			*f.q = *f.o
+		}
+	}
+}
+
+func isSet(b *bool) bool {
+	return b != nil && *b
+}
+
+// Speed is the clock speed in instructions per second of the rom or else of the platform.
+func (r ROMInfo) Speed(p Platform) int {
+	if r.TickRate > 0 {
+		return r.TickRate * 60
+	}
+	return p.DefaultTickRate * 60
+}
+
+// Palette is the color of each bitplane combination, nil when the rom has no valid colors.
+func (r ROMInfo) Palette() color.Palette {
+	p, err := ParsePalette(r.Colors.Pixels)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// DatabaseSetting is a setting the rom database configures when a rom is found in it.
+type DatabaseSetting int
+
+const (
+	DatabaseVariant DatabaseSetting = 1 << iota
+	DatabaseQuirks
+	DatabaseSpeed
+	DatabaseLoadAddress
+	DatabaseColors
+	DatabaseKeys
+
+	DatabaseAll = DatabaseVariant | DatabaseQuirks | DatabaseSpeed | DatabaseLoadAddress | DatabaseColors | DatabaseKeys
+)
+
+// SetROMDatabase sets the database the roms are looked up in when they are loaded and the settings it configures,
+// settings the user chose are left out. A nil database disables the lookup.
+func (c *Chip8) SetROMDatabase(db *ROMDatabase, settings DatabaseSetting) {
+	c.romdb = db
+	c.romdbSettings = settings
+}
+
+// lookupROM looks the rom up in the database and changes the settings to the ones the database has for the rom, nil
+// when the rom is not in it.
+func (c *Chip8) lookupROM(rom []byte, s *machineSettings) *ROMInfo {
+	info, ok := c.romdb.Lookup(HashROM(rom))
+	if !ok {
+		return nil
+	}
+	set := func(setting DatabaseSetting) bool { return c.romdbSettings&setting != 0 }
+	if p, ok := c.romdb.Platform(info); ok {
+		if set(DatabaseVariant) {
+			s.variant = platformVariants[p.ID]
+		}
+		if set(DatabaseQuirks) {
+			s.quirks = info.Quirks(p)
+		}
+		if set(DatabaseSpeed) {
+			s.speed = info.Speed(p)
+		}
+	}
+	if set(DatabaseLoadAddress) && info.StartAddress > 0 {
+		s.loadAddr = uint16(info.StartAddress)
+	}
+	if set(DatabaseColors) {
+		if p := info.Palette(); p != nil {
+			s.palette = p
+		}
+	}
+	return &info
+}
+
+// keyHintsOf are the keys the rom database names for directions, nil when it does not configure the keys.
+func (c *Chip8) keyHintsOf(info *ROMInfo) map[string]byte {
+	if info == nil || c.romdbSettings&DatabaseKeys == 0 {
+		return nil
+	}
+	hints := make(map[string]byte)
+	for name, key := range info.Keys {
+		if key >= 0 && key < keyNumbers {
+			hints[name] = byte(key)
+		}
+	}
+	return hints
+}
+
+// pressKeyHint presses the key the rom database names for the direction, nothing when it names none.
+func (c *Chip8) pressKeyHint(direction string) {
+	if key, ok := c.keyHints[direction]; ok {
+		c.sendKeyboardInterrupt(key)
+	}
+}
@@ -0,0 +1,89 @@
+package chip8
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeROM writes a rom to a file in the test directory.
+func writeROM(t *testing.T, name string, rom []byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestROMDatabaseLookup adds a program to the bundled database and looks its rom up by the sha1, the first platform
+// the emulator supports is used with the quirks the rom changes on it.
+func TestROMDatabaseLookup(t *testing.T) {
+	rom := []byte{0x00, 0xE0, 0x12, 0x00}
+	db, err := NewROMDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs := fmt.Sprintf(`[{"title": "Clear", "authors": ["A", "B"], "roms": {"%s": {"platforms": ["megachip8", "superchip"],
+		"quirkyPlatforms": {"superchip": {"jump": false}}, "keys": {"up": 5}}}}]`, strings.ToUpper(HashROM(rom)))
+	if err := db.AddPrograms(strings.NewReader(programs)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Lookup(HashROM([]byte{0x12, 0x00})); ok {
+		t.Error("a rom that is not in the database was found")
+	}
+	info, ok := db.Lookup(HashROM(rom))
+	if !ok {
+		t.Fatalf("%s is not found", HashROM(rom))
+	}
+	if got, want := info.Name(), "Clear by A, B"; got != want {
+		t.Errorf("name %q, want %q", got, want)
+	}
+	p, ok := db.Platform(info)
+	if !ok || p.ID != "superchip" || platformVariants[p.ID] != VariantSChip {
+		t.Fatalf("platform %q, want superchip", p.ID)
+	}
+	want := Quirks{Shift: true, Clip: true, LoadStore: IndexUnchanged}
+	if got := info.Quirks(p); got != want {
+		t.Errorf("quirks %s, want %s", got, want)
+	}
+	if got := info.Speed(p); got != 30*60 {
+		t.Errorf("speed %d Hz, want the tickrate of the platform", got)
+	}
+}
+
+// TestROMDatabaseRestoresSettings opens a rom of the rom database and then one that is not in it, the second one
+// runs with the settings of the user again.
+func TestROMDatabaseRestoresSettings(t *testing.T) {
+	eti := []byte{0x16, 0x00}
+	other := []byte{0x12, 0x00}
+	db, err := NewROMDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs := fmt.Sprintf(`[{"title": "ETI", "roms": {"%s": {"platforms": ["superchip"], "startAddress": 1536, "tickrate": 20}}}]`, HashROM(eti))
+	if err := db.AddPrograms(strings.NewReader(programs)); err != nil {
+		t.Fatal(err)
+	}
+	user := QuirksPresets["modern"]
+	c := newTestChip(t, user)
+	c.SetVariant(VariantChip8)
+	c.SetSpeed(700)
+	c.SetLoadAddress(DefaultLoadAddress)
+	c.SetROMDatabase(db, DatabaseAll)
+
+	if _, err := c.openROM(writeROM(t, "eti.ch8", eti)); err != nil {
+		t.Fatal(err)
+	}
+	if c.variant != VariantSChip || c.loadAddr != 0x600 || c.speed != 1200 || c.quirks == user || c.romInfo == nil {
+		t.Fatalf("database rom runs as %s at %03X, %d Hz, quirks %s", c.variant, c.loadAddr, c.speed, c.quirks)
+	}
+	if _, err := c.openROM(writeROM(t, "other.ch8", other)); err != nil {
+		t.Fatal(err)
+	}
+	if c.variant != VariantChip8 || c.loadAddr != DefaultLoadAddress || c.speed != 700 || c.quirks != user || c.romInfo != nil {
+		t.Errorf("the next rom runs as %s at %03X, %d Hz, quirks %s, want the settings of the user", c.variant, c.loadAddr, c.speed, c.quirks)
+	}
+}
@@ -0,0 +1,259 @@
+package chip8
+
+import (
+	"time"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+// run starts the frame loop. While it runs the machine is only touched from the loop, everything else
+// talks to it through the signals.
+func (c *Chip8) run() {
+	if c.isRunning() || c.exited {
+		return
+	}
+	c.debug.continueExecution()
+	c.stopSignal = make(chan struct{})
+	c.stopped = make(chan struct{})
+	c.running = true
+	go c.runFrames()
+}
+
+// stop stops the frame loop and waits until it returned.
+func (c *Chip8) stop() {
+	if c.running {
+		close(c.stopSignal)
+		<-c.stopped
+		c.running = false
+	}
+}
+
+// halt stops the rom on request of the user, a step over or out that was running is abandoned.
+func (c *Chip8) halt() {
+	c.stop()
+	c.debug.until = nil
+}
+
+// isRunning reports whether the frame loop runs, the loop stops by itself when the debugger breaks.
+func (c *Chip8) isRunning() bool {
+	if !c.running {
+		return false
+	}
+	select {
+	case <-c.stopped:
+		c.running = false
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Chip8) runFrames() {
+	defer close(c.stopped)
+	frameTimer := time.NewTicker(frameRate)
+	defer frameTimer.Stop()
+	for {
+		select {
+		case <-c.stopSignal:
+			return
+		case <-frameTimer.C:
+			c.emulateFrame()
+			if c.debug.broken {
+				return
+			}
+		case ips := <-c.speedSignal:
+			c.speed = ips
+		case k := <-c.keyboardInterrupt:
+			c.keyHold = keyboardResetFrames
+			c.pressKey(k)
+		}
+	}
+}
+
+// emulateFrame runs the instructions of one 60 Hz frame, ticks the timers and publishes the frame.
+func (c *Chip8) emulateFrame() {
+	c.history.newFrame = true
+	c.cycleDebt += c.speed
+	n := c.cycleDebt / timerHz
+	c.cycleDebt %= timerHz
+	for i := 0; i < n && !c.exited && !c.debug.broken; i++ {
+		c.emulateCycle()
+		if c.spriteDrawn && c.quirks.DisplayWait {
+			break
+		}
+	}
+	c.updateTimers()
+	if c.keyHold > 0 {
+		c.keyHold--
+		if c.keyHold == 0 {
+			c.clearKeys()
+		}
+	}
+	c.publish()
+}
+
+// emulateCycle runs an instruction and handles its error with the error policy.
+func (c *Chip8) emulateCycle() {
+	if err := c.step(); err != nil {
+		c.handleError(err)
+	}
+}
+
+// step runs an instruction, the error of an instruction that failed is returned after it ran as far as it could.
+func (c *Chip8) step() error {
+	c.spriteDrawn = false
+	if c.exited || c.waitingKey {
+		return nil
+	}
+	if reason := c.debug.before(c); reason != "" {
+		c.breakExecution(reason)
+		return nil
+	}
+	if int(c.pc)+1 >= c.memSize {
+		return MemoryAccessError{PC: c.pc, Addr: c.pc, Size: c.memSize}
+	}
+	c.execAddr, c.fault = c.pc, nil
+	c.history.begin(c.cpu)
+	c.fetch()
+	c.decode()
+	c.history.end()
+	c.cycle++
+	if c.trace.active {
+		c.traceInstruction(c.execAddr)
+	}
+	if reason := c.debug.after(c); reason != "" {
+		c.breakExecution(reason)
+	}
+	return c.fault
+}
+
+// publish sends the screen when it changed and updates the info of the TUI.
+func (c *Chip8) publish() {
+	if c.drawFlag {
+		pixels := make([]byte, c.width*c.height)
+		copy(pixels, c.screenBuf[:])
+		c.drawSignal <- emulator.Frame{Width: c.width, Height: c.height, Pixels: pixels, Palette: c.palette}
+		c.drawFlag = false
+	}
+	if c.SetEmuInfo != nil {
+		c.SetEmuInfo(c)
+	}
+}
+
+// Step runs a single instruction while the rom is stopped. Keys pressed before the step are released afterwards
+// because there is no frame loop running to release them, the timers are updated every speed/60 steps.
+func (c *Chip8) Step() {
+	if c.isRunning() {
+		return
+	}
+	c.debug.continueExecution()
+	c.emulateCycle()
+	c.stepCycles++
+	if c.stepCycles >= c.InstructionsPerFrame() {
+		c.updateTimers()
+		c.stepCycles = 0
+	}
+	c.clearKeys()
+	c.publish()
+}
+
+// StepFrame runs a single frame while the rom is stopped, keys pressed before the step are released afterwards.
+func (c *Chip8) StepFrame() {
+	if c.isRunning() {
+		return
+	}
+	c.debug.continueExecution()
+	c.emulateFrame()
+	c.stepCycles = 0
+	c.clearKeys()
+}
+
+// SetSpeed sets the clock to ips instructions per second, roms that do not set their own speed run at it.
+func (c *Chip8) SetSpeed(ips int) {
+	c.defaults.speed = clampSpeed(ips)
+	c.setSpeed(ips)
+}
+
+// setSpeed sets the clock without changing the speed of the user, for the speed of a rom.
+func (c *Chip8) setSpeed(ips int) {
+	ips = clampSpeed(ips)
+	if !c.isRunning() {
+		c.speed = ips
+		return
+	}
+	select {
+	case c.speedSignal <- ips:
+	case <-c.stopped:
+		c.speed = ips
+	}
+}
+
+func clampSpeed(ips int) int {
+	if ips < 1 {
+		return 1
+	} else if ips > maxSpeed {
+		return maxSpeed
+	}
+	return ips
+}
+
+// Speed returns the clock speed in instructions per second, it is only safe to call while the rom is stopped
+// or from the frame loop.
+func (c *Chip8) Speed() int {
+	return c.speed
+}
+
+// InstructionsPerFrame is the number of instructions run during one 60 Hz frame, at least 1.
+func (c *Chip8) InstructionsPerFrame() int {
+	if ipf := c.speed / timerHz; ipf > 1 {
+		return ipf
+	}
+	return 1
+}
+
+func (c *Chip8) speedUp() {
+	c.changeSpeed(1)
+}
+
+func (c *Chip8) speedDown() {
+	c.changeSpeed(-1)
+}
+
+// changeSpeed changes the speed by 10% so it can be adjusted quickly from a few to thousands of instructions
+// per second. The current speed is owned by the frame loop, so it is stopped while changing it.
+func (c *Chip8) changeSpeed(direction int) {
+	c.paused(func() {
+		step := c.speed / 10
+		if step < 1 {
+			step = 1
+		}
+		c.SetSpeed(c.speed + direction*step)
+	})
+	c.refresh()
+}
+
+// paused runs f with the frame loop stopped and resumes it afterwards when it was running.
+func (c *Chip8) paused(f func()) {
+	wasRunning := c.isRunning()
+	c.stop()
+	f()
+	if wasRunning {
+		c.run()
+	}
+}
+
+// refresh publishes changes made while the rom is stopped, a running rom publishes every frame.
+func (c *Chip8) refresh() {
+	if !c.isRunning() {
+		c.publish()
+	}
+}
+
+func (c *Chip8) togglePause() {
+	if c.isRunning() {
+		c.halt()
+		c.refresh()
+	} else {
+		c.run()
+	}
+}
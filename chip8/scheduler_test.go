@@ -0,0 +1,128 @@
+package chip8
+
+import (
+	"testing"
+	"time"
+)
+
+// loop is a rom that jumps to itself.
+var loop = []byte{0x12, 0x00}
+
+func TestInstructionsPerFrame(t *testing.T) {
+	for _, speed := range []int{1, 59, 60, 700, 1000} {
+		c := newTestChip(t, Quirks{}, loop...)
+		c.SetSpeed(speed)
+		for frame := 1; frame <= 2*timerHz; frame++ {
+			c.emulateFrame()
+			// the remainder of a frame carries over, so every frame runs within one instruction of its share
+			want := uint64(speed * frame / timerHz)
+			if c.cycle != want {
+				t.Fatalf("%d Hz: %d instructions after %d frames, want %d", speed, c.cycle, frame, want)
+			}
+		}
+	}
+}
+
+func TestTimersTickOncePerFrame(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	c.SetSpeed(700)
+	c.delayTimer, c.soundTimer = 10, 2
+	for i := 0; i < 3; i++ {
+		c.emulateFrame()
+	}
+	if c.delayTimer != 7 || c.soundTimer != 0 {
+		t.Errorf("after 3 frames delay=%d sound=%d, want 7 and 0", c.delayTimer, c.soundTimer)
+	}
+	c.StepFrame()
+	if c.delayTimer != 6 {
+		t.Errorf("after a frame step delay=%d, want 6", c.delayTimer)
+	}
+}
+
+func TestStep(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x60, 0x01, 0x61, 0x02, 0x12, 0x04)
+	c.SetSpeed(3 * timerHz)
+	c.delayTimer = 10
+	c.Step()
+	c.Step()
+	if c.v[0] != 1 || c.v[1] != 2 || c.pc != 0x204 || c.cycle != 2 {
+		t.Errorf("after 2 steps V0=%d V1=%d PC=%03X cycle=%d, want 1, 2, 204 and 2", c.v[0], c.v[1], c.pc, c.cycle)
+	}
+	if c.delayTimer != 10 {
+		t.Errorf("the timers ticked after 2 of 3 instructions of a frame, delay=%d", c.delayTimer)
+	}
+	c.Step()
+	if c.delayTimer != 9 {
+		t.Errorf("the timers did not tick after the instructions of a frame, delay=%d", c.delayTimer)
+	}
+}
+
+func TestRunStopsAtBreakpoint(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x60, 0x01, 0x61, 0x02, 0x62, 0x03, 0x12, 0x06)
+	if err := c.AddBreak("204"); err != nil {
+		t.Fatal(err)
+	}
+	c.run()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.isRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("the frame loop did not stop at the breakpoint")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.debug.broken || c.pc != 0x204 || c.v[2] != 0 {
+		t.Errorf("broken=%t PC=%03X V2=%d, want a break before 204", c.debug.broken, c.pc, c.v[2])
+	}
+	if got := c.status(); got != "break: "+c.debug.reason {
+		t.Errorf("status %q after the break", got)
+	}
+	c.stop()
+
+	// running again continues past the breakpoint, the loop is stopped after a few frames to look at the machine
+	for c.cycle == 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the rom did not continue from the breakpoint")
+		}
+		c.run()
+		if !c.isRunning() {
+			t.Fatal("the frame loop did not start again")
+		}
+		time.Sleep(2 * frameRate)
+		c.halt()
+	}
+	if c.isRunning() || c.status() != "stopped" {
+		t.Errorf("running=%t status %q after halt", c.isRunning(), c.status())
+	}
+	if c.v[2] != 3 || c.pc != 0x206 {
+		t.Errorf("PC=%03X V2=%d after continuing, want the loop at 206 and V2=3", c.pc, c.v[2])
+	}
+}
+
+// TestParallelMachines runs machines side by side, machines with the same seed run the same way.
+func TestParallelMachines(t *testing.T) {
+	// V1 += random 0xFF in a loop
+	rom := []byte{0xC0, 0xFF, 0x81, 0x04, 0x12, 0x00}
+	results := make([]byte, 4)
+	for i := range results {
+		i := i
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			c := newTestChip(t, Quirks{}, rom...)
+			c.SetSeed(42)
+			c.reset("test.ch8", rom)
+			c.SetSpeed(600)
+			for frame := 0; frame < timerHz; frame++ {
+				c.emulateFrame()
+			}
+			results[i] = c.v[1]
+		})
+	}
+	t.Cleanup(func() {
+		for i := range results {
+			if results[i] != results[0] {
+				t.Errorf("machines with the same seed ended with V1 % X", results)
+				return
+			}
+		}
+	})
+}
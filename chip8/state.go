@@ -0,0 +1,170 @@
+package chip8
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const stateVersion = 1
+
+// state is the save state format, a json document so it stays readable and can be extended.
+type state struct {
+	Version int    `json:"version"`
+	ROMHash string `json:"romHash"`
+
+	Variant Variant `json:"variant"`
+	Quirks  Quirks  `json:"quirks"`
+	Speed   int     `json:"speed"`
+
+	Opcode     uint16            `json:"opcode"`
+	I          uint16            `json:"i"`
+	PC         uint16            `json:"pc"`
+	Stack      [stackSize]uint16 `json:"stack"`
+	SP         byte              `json:"sp"`
+	V          [vRegSize]byte    `json:"v"`
+	Memory     []byte            `json:"memory"`
+	Key        [keyNumbers]byte  `json:"key"`
+	DelayTimer byte              `json:"delayTimer"`
+	SoundTimer byte              `json:"soundTimer"`
+	RNG        uint64            `json:"rng"`
+
+	Screen []byte `json:"screen"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Hires  bool   `json:"hires"`
+	Planes byte   `json:"planes"`
+
+	WaitingKey   bool `json:"waitingKey"`
+	WaitRegister byte `json:"waitRegister"`
+	WaitHeld     bool `json:"waitHeld"`
+	WaitKey      byte `json:"waitKey"`
+
+	RPL          [rplSize]byte          `json:"rpl"`
+	AudioPattern [audioPatternSize]byte `json:"audioPattern"`
+	Pitch        byte                   `json:"pitch"`
+}
+
+// Snapshot returns the full machine state, the rom is paused while it is taken.
+func (c *Chip8) Snapshot() ([]byte, error) {
+	var s state
+	c.paused(func() { s = c.state() })
+	return json.Marshal(s)
+}
+
+// Restore loads a state taken with Snapshot, states of another rom or format version are refused.
+func (c *Chip8) Restore(data []byte) error {
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return InvalidStateError{Err: err}
+	}
+	if s.Version != stateVersion {
+		return StateVersionError{Version: s.Version}
+	}
+	if s.ROMHash != c.romHash {
+		return StateROMMismatchError{}
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	c.paused(func() { c.setState(s) })
+	c.refresh()
+	return nil
+}
+
+// validate refuses states the machine can not run, e.g. states that were edited by hand.
+func (s state) validate() error {
+	invalid := func(format string, a ...interface{}) error {
+		return InvalidStateError{Err: fmt.Errorf(format, a...)}
+	}
+	switch {
+	case s.Variant.String() == "":
+		return invalid("unknown variant %d", s.Variant)
+	case len(s.Memory) != s.Variant.MemorySize():
+		return invalid("%d bytes of memory, %s has %d", len(s.Memory), s.Variant, s.Variant.MemorySize())
+	case s.Speed < 1 || s.Speed > maxSpeed:
+		return invalid("speed %d out of range", s.Speed)
+	case int(s.SP) > stackSize:
+		return invalid("sp %d past the stack of %d", s.SP, stackSize)
+	case s.WaitRegister >= vRegSize:
+		return invalid("wait register %d is not a register", s.WaitRegister)
+	case !s.Variant.hasResolution(s.Width, s.Height) || s.Hires != (s.Width == hiresScreenWidth):
+		return invalid("%s has no %dx%d resolution", s.Variant, s.Width, s.Height)
+	case len(s.Screen) != s.Width*s.Height:
+		return invalid("%d pixels for a %dx%d screen", len(s.Screen), s.Width, s.Height)
+	case s.Planes > 3:
+		return invalid("planes %d, there are 2 bitplanes", s.Planes)
+	case s.RNG == 0:
+		return invalid("the random number generator state is 0")
+	}
+	return nil
+}
+
+func (c *Chip8) state() state {
+	s := state{
+		Version:      stateVersion,
+		ROMHash:      c.romHash,
+		Variant:      c.variant,
+		Quirks:       c.quirks,
+		Speed:        c.speed,
+		Opcode:       c.opcode,
+		I:            c.i,
+		PC:           c.pc,
+		Stack:        c.stack,
+		SP:           c.sp,
+		V:            c.v,
+		Memory:       append([]byte(nil), c.memory[:c.memSize]...),
+		Key:          c.key,
+		DelayTimer:   c.delayTimer,
+		SoundTimer:   c.soundTimer,
+		RNG:          c.rng.state,
+		Screen:       append([]byte(nil), c.screenBuf[:c.width*c.height]...),
+		Width:        c.width,
+		Height:       c.height,
+		Hires:        c.hires,
+		Planes:       c.planes,
+		WaitingKey:   c.waitingKey,
+		WaitRegister: c.waitRegister,
+		WaitHeld:     c.waitHeld,
+		WaitKey:      c.waitKey,
+		RPL:          c.rpl,
+		AudioPattern: c.audioPattern,
+		Pitch:        c.pitch,
+	}
+	return s
+}
+
+func (c *Chip8) setState(s state) {
+	c.variant = s.Variant
+	c.quirks = s.Quirks
+	c.speed = s.Speed
+	c.opcode = s.Opcode
+	c.i = s.I
+	c.pc = s.PC
+	c.stack = s.Stack
+	c.sp = s.SP
+	c.v = s.V
+	c.memSize = len(s.Memory)
+	copy(c.memory[:], s.Memory)
+	c.key = s.Key
+	c.delayTimer = s.DelayTimer
+	c.soundTimer = s.SoundTimer
+	c.rng.state = s.RNG
+	c.width = s.Width
+	c.height = s.Height
+	c.hires = s.Hires
+	c.planes = s.Planes
+	c.clearScreen()
+	copy(c.screenBuf[:], s.Screen)
+	c.waitingKey = s.WaitingKey
+	c.waitRegister = s.WaitRegister
+	c.waitHeld = s.WaitHeld
+	c.waitKey = s.WaitKey
+	c.rpl = s.RPL
+	c.audioPattern = s.AudioPattern
+	c.pitch = s.Pitch
+	for i := range c.vChanged {
+		c.vChanged[i] = true
+	}
+	c.history.clear()
+	c.drawFlag = true
+}
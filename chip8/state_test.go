@@ -0,0 +1,70 @@
+package chip8
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	c := newTestChip(t, Quirks{}, 0x60, 0x2A, 0x22, 0x06, 0x12, 0x04, 0x00, 0xEE)
+	mustStep(t, c)
+	mustStep(t, c)
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustStep(t, c)
+	c.v[0] = 0
+	if err := c.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if c.v[0] != 0x2A || c.pc != 0x206 || c.sp != 1 {
+		t.Errorf("restored V0=%02X PC=%03X SP=%d, want 2A, 206 and 1", c.v[0], c.pc, c.sp)
+	}
+	mustStep(t, c)
+	if c.pc != 0x204 || c.sp != 0 {
+		t.Errorf("00EE after the restore: PC=%03X SP=%d, want 204 and 0", c.pc, c.sp)
+	}
+}
+
+func TestRestoreRefusesInvalidStates(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		field string
+		value interface{}
+	}{
+		{"variant", 7},
+		{"memory", make([]byte, memorySize/2)},
+		{"speed", 0},
+		{"sp", 200},
+		{"sp", stackSize + 1},
+		{"waitRegister", 16},
+		{"width", 0},
+		{"height", 0},
+		{"width", hiresScreenWidth},
+		{"planes", 4},
+		{"rng", 0},
+		{"screen", []byte{0}},
+	} {
+		var s map[string]interface{}
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatal(err)
+		}
+		s[tc.field] = tc.value
+		edited, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Restore(edited); !errors.As(err, new(InvalidStateError)) {
+			t.Errorf("%s = %v: %v, want InvalidStateError", tc.field, tc.value, err)
+		}
+	}
+	if c.pc != 0x200 || c.sp != 0 {
+		t.Errorf("a refused state changed the machine: PC=%03X SP=%d", c.pc, c.sp)
+	}
+}
@@ -0,0 +1,226 @@
+package chip8
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols maps addresses to the labels of the source a rom was assembled from, a nil *Symbols has no labels.
+type Symbols struct {
+	names map[uint16]string
+	addrs map[string]uint16
+	order []uint16 // addresses with a label in ascending order
+}
+
+// NewSymbols returns the symbols of a map of labels to addresses.
+func NewSymbols(labels map[string]uint16) *Symbols {
+	s := &Symbols{names: make(map[uint16]string), addrs: make(map[string]uint16)}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.add(name, labels[name])
+	}
+	s.sort()
+	return s
+}
+
+// ParseSymbols reads a symbol file, either lines with an address and a label in any order ("0x0202 main", the
+// output of chip8 asm) or a JSON object of labels and addresses ({"main": 514} or {"main": "0x202"}). Lines
+// starting with # are comments. When several labels share an address the first one names it, the first in
+// alphabetical order for JSON.
+func ParseSymbols(r io.Reader) (*Symbols, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &Symbols{names: make(map[uint16]string), addrs: make(map[string]uint16)}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := s.parseJSON(trimmed); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, InvalidSymbolError{Line: line, Text: text}
+		}
+		addr, err := parseSymbolAddr(fields[0])
+		name := fields[1]
+		if err != nil {
+			if addr, err = parseSymbolAddr(fields[1]); err != nil {
+				return nil, InvalidSymbolError{Line: line, Text: text}
+			}
+			name = fields[0]
+		}
+		s.add(name, addr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	s.sort()
+	return s, nil
+}
+
+func (s *Symbols) parseJSON(data []byte) error {
+	var labels map[string]interface{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var addr uint16
+		var err error
+		switch v := labels[name].(type) {
+		case float64:
+			if v < 0 || v > 0xFFFF {
+				err = strconv.ErrRange
+			}
+			addr = uint16(v)
+		case string:
+			addr, err = parseSymbolAddr(v)
+		default:
+			err = strconv.ErrSyntax
+		}
+		if err != nil {
+			return InvalidSymbolError{Text: fmt.Sprintf("%q: %v", name, labels[name])}
+		}
+		s.add(name, addr)
+	}
+	s.sort()
+	return nil
+}
+
+// parseSymbolAddr parses a hexadecimal address with a 0x prefix or a decimal one.
+func parseSymbolAddr(text string) (uint16, error) {
+	if strings.HasPrefix(strings.ToLower(text), "0x") {
+		return parseHex(text)
+	}
+	n, err := strconv.ParseUint(text, 10, 16)
+	return uint16(n), err
+}
+
+func (s *Symbols) add(name string, addr uint16) {
+	s.addrs[name] = addr
+	if _, ok := s.names[addr]; !ok {
+		s.names[addr] = name
+	}
+}
+
+func (s *Symbols) sort() {
+	s.order = s.order[:0]
+	for addr := range s.names {
+		s.order = append(s.order, addr)
+	}
+	sort.Slice(s.order, func(i, j int) bool { return s.order[i] < s.order[j] })
+}
+
+// Name returns the label of the address.
+func (s *Symbols) Name(addr uint16) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	name, ok := s.names[addr]
+	return name, ok
+}
+
+// Addr returns the address of the label.
+func (s *Symbols) Addr(name string) (uint16, bool) {
+	if s == nil {
+		return 0, false
+	}
+	addr, ok := s.addrs[name]
+	return addr, ok
+}
+
+// Describe names an address by the closest label before it, "main" or "main+0x4", an empty string when there is
+// no label before it.
+func (s *Symbols) Describe(addr uint16) string {
+	if s == nil {
+		return ""
+	}
+	i := sort.Search(len(s.order), func(i int) bool { return s.order[i] > addr }) - 1
+	if i < 0 {
+		return ""
+	}
+	name := s.names[s.order[i]]
+	if d := addr - s.order[i]; d > 0 {
+		return fmt.Sprintf("%s+0x%X", name, d)
+	}
+	return name
+}
+
+// SymbolsFile returns the symbol file chip8 asm writes next to the rom.
+func SymbolsFile(rom string) string {
+	return strings.TrimSuffix(rom, filepath.Ext(rom)) + ".sym"
+}
+
+// ReadSymbols reads a symbol file, see ParseSymbols for the format.
+func ReadSymbols(file string) (*Symbols, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := ParseSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return s, nil
+}
+
+// LoadSymbols reads the labels shown in the panels and accepted as addresses by the breakpoint commands.
+func (c *Chip8) LoadSymbols(file string) error {
+	s, err := ReadSymbols(file)
+	if err != nil {
+		return err
+	}
+	c.paused(func() { c.symbols = s })
+	return nil
+}
+
+// loadRomSymbols replaces the labels with the symbol file next to the rom, if there is one.
+func (c *Chip8) loadRomSymbols(rom string) error {
+	c.paused(func() { c.symbols = nil })
+	if _, err := os.Stat(SymbolsFile(rom)); err != nil {
+		return nil
+	}
+	return c.LoadSymbols(SymbolsFile(rom))
+}
+
+// parseAddr parses a label or a hexadecimal address.
+func (c *Chip8) parseAddr(s string) (uint16, error) {
+	if addr, ok := c.symbols.Addr(s); ok {
+		return addr, nil
+	}
+	return parseHex(s)
+}
+
+// addrName is the label of the address or the address in hexadecimal.
+func (c *Chip8) addrName(addr uint16) string {
+	if name, ok := c.symbols.Name(addr); ok {
+		return name
+	}
+	return fmt.Sprintf("0x%03X", addr)
+}
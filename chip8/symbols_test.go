@@ -0,0 +1,106 @@
+package chip8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		src   string
+		addrs map[string]uint16
+		names map[uint16]string // the label that names each address
+	}{
+		{"asm output", "0x0200 main\n0x0206 loop\n0x0206 again\n",
+			map[string]uint16{"main": 0x200, "loop": 0x206, "again": 0x206}, map[uint16]string{0x200: "main", 0x206: "loop"}},
+		{"label first", "# labels\n\nmain 512\n  sprite 0X300  \n",
+			map[string]uint16{"main": 0x200, "sprite": 0x300}, map[uint16]string{0x200: "main", 0x300: "sprite"}},
+		{"json", `  {"main": 514, "data": "0x300", "alias": "770"}`,
+			map[string]uint16{"main": 0x202, "data": 0x300, "alias": 0x302}, map[uint16]string{0x202: "main", 0x300: "data", 0x302: "alias"}},
+		{"json sorted by name", `{"loop": 518, "again": 518}`,
+			map[string]uint16{"loop": 0x206, "again": 0x206}, map[uint16]string{0x206: "again"}},
+		{"empty", "", map[string]uint16{}, map[uint16]string{}},
+	} {
+		s, err := ParseSymbols(strings.NewReader(tc.src))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for name, want := range tc.addrs {
+			if addr, ok := s.Addr(name); !ok || addr != want {
+				t.Errorf("%s: %s at %03X, want %03X", tc.name, name, addr, want)
+			}
+		}
+		if len(s.addrs) != len(tc.addrs) || len(s.names) != len(tc.names) {
+			t.Errorf("%s: %d labels naming %d addresses, want %d and %d", tc.name, len(s.addrs), len(s.names), len(tc.addrs), len(tc.names))
+		}
+		for addr, want := range tc.names {
+			if name, ok := s.Name(addr); !ok || name != want {
+				t.Errorf("%s: %03X is named %q, want %q", tc.name, addr, name, want)
+			}
+		}
+	}
+}
+
+func TestParseSymbolsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		line int
+	}{
+		{"0x200 main\nmain\n", 2},
+		{"0x200 main extra", 1},
+		{"main loop", 1},
+		{"0x10000 main", 1},
+		{"# comment\n-1 main", 2},
+		{`{"main": -1}`, 0},
+		{`{"main": 65536}`, 0},
+		{`{"main": "0xZZ"}`, 0},
+		{`{"main": true}`, 0},
+	} {
+		_, err := ParseSymbols(strings.NewReader(tc.src))
+		var invalid InvalidSymbolError
+		if !errors.As(err, &invalid) || invalid.Line != tc.line {
+			t.Errorf("%q: %v, want an invalid symbol on line %d", tc.src, err, tc.line)
+		}
+	}
+	if _, err := ParseSymbols(strings.NewReader(`{"main": 512`)); err == nil {
+		t.Error("invalid json was parsed")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	s := NewSymbols(map[string]uint16{"main": 0x200, "draw": 0x210, "again": 0x210})
+	for _, tc := range []struct {
+		addr uint16
+		want string
+	}{
+		{0x1FE, ""},
+		{0x200, "main"},
+		{0x204, "main+0x4"},
+		{0x20F, "main+0xF"},
+		{0x210, "again"},
+		{0x300, "again+0xF0"},
+	} {
+		if got := s.Describe(tc.addr); got != tc.want {
+			t.Errorf("%03X described as %q, want %q", tc.addr, got, tc.want)
+		}
+	}
+	var none *Symbols
+	if got := none.Describe(0x200); got != "" {
+		t.Errorf("nil symbols describe 200 as %q", got)
+	}
+}
+
+// TestBreakAtLabel checks that breakpoints accept labels and name the address by its label.
+func TestBreakAtLabel(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	c.symbols = NewSymbols(map[string]uint16{"main": 0x200, "draw": 0x2A4})
+	if err := c.AddBreak("draw if v0 == 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Breaks(); len(got) != 1 || got[0] != "draw if v0 == 0x1" {
+		t.Errorf("breaks %q", got)
+	}
+}
@@ -0,0 +1,630 @@
+package chip8
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TraceFormat is the file format of an instruction trace.
+type TraceFormat int
+
+const (
+	TraceText   TraceFormat = iota // a line per instruction, see writeText
+	TraceBinary                    // a compact record per instruction, see writeBinary
+)
+
+var traceFormatNames = map[TraceFormat]string{
+	TraceText:   "text",
+	TraceBinary: "binary",
+}
+
+func (f TraceFormat) String() string {
+	return traceFormatNames[f]
+}
+
+func ParseTraceFormat(s string) (TraceFormat, error) {
+	for format, name := range traceFormatNames {
+		if strings.EqualFold(name, s) {
+			return format, nil
+		}
+	}
+	return TraceText, UnknownTraceFormatError{Name: s, Valid: TraceFormatNames()}
+}
+
+func TraceFormatNames() []string {
+	return []string{TraceText.String(), TraceBinary.String()}
+}
+
+const (
+	traceTextHeader = "# chip8 trace: cycle pc opcode i sp dt st changed-registers memory-writes ; instruction"
+	traceMagic      = "CH8T"
+	traceVersion    = 1
+)
+
+// TraceRecord is an executed instruction with the state after it ran.
+type TraceRecord struct {
+	Cycle   uint64 // instructions executed since the rom was loaded, starting at 1
+	PC      uint16 // address of the instruction
+	Opcode  uint16
+	Long    uint16 // operand of F000 NNNN
+	I       uint16
+	SP      byte
+	DT      byte
+	ST      byte
+	V       [vRegSize]byte
+	Changed uint16 // bit X is set when VX changed since the previous record of the trace
+	Writes  []MemWrite
+	Text    string // the instruction in Octo syntax
+}
+
+// MemWrite is a byte an instruction stored in memory.
+type MemWrite struct {
+	Addr  uint16
+	Value byte
+}
+
+// writeText writes a record as a line: cycle, pc, opcode, i, sp, dt, st, the changed registers and the memory
+// writes, then the instruction after a semicolon. The opcode of F000 NNNN is followed by its operand, F0001234.
+//
+//	00000012 0204 F033 i=0300 sp=1 dt=00 st=00 v3=2A [0300]=00 [0301]=04 [0302]=02 ; bcd v3
+func writeText(w io.Writer, r *TraceRecord) error {
+	_, err := io.WriteString(w, r.String()+"\n")
+	return err
+}
+
+// String formats the record as a line of a text trace.
+func (r TraceRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08d %04X %04X", r.Cycle, r.PC, r.Opcode)
+	if r.Opcode == 0xF000 {
+		fmt.Fprintf(&b, "%04X", r.Long)
+	}
+	fmt.Fprintf(&b, " i=%04X sp=%X dt=%02X st=%02X", r.I, r.SP, r.DT, r.ST)
+	for x := range r.V {
+		if r.Changed&(1<<x) != 0 {
+			fmt.Fprintf(&b, " v%X=%02X", x, r.V[x])
+		}
+	}
+	for _, m := range r.Writes {
+		fmt.Fprintf(&b, " [%04X]=%02X", m.Addr, m.Value)
+	}
+	fmt.Fprintf(&b, " ; %s", r.Text)
+	return b.String()
+}
+
+// Diff returns the differences of the pc, opcode, registers, I, SP and memory writes of two records, e.g. "v3: 2A != 2B".
+// The timers are only compared when timers is set, the cycle and the instruction text never.
+func (r TraceRecord) Diff(o TraceRecord, timers bool) []string {
+	var diffs []string
+	word := func(name string, a, b uint16) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: %04X != %04X", name, a, b))
+		}
+	}
+	octet := func(name string, a, b byte) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: %02X != %02X", name, a, b))
+		}
+	}
+	word("pc", r.PC, o.PC)
+	word("opcode", r.Opcode, o.Opcode)
+	if r.Opcode == 0xF000 && o.Opcode == 0xF000 {
+		word("long", r.Long, o.Long)
+	}
+	for x := range r.V {
+		octet(fmt.Sprintf("v%X", x), r.V[x], o.V[x])
+	}
+	word("i", r.I, o.I)
+	octet("sp", r.SP, o.SP)
+	if timers {
+		octet("dt", r.DT, o.DT)
+		octet("st", r.ST, o.ST)
+	}
+	if !equalWrites(r.Writes, o.Writes) {
+		diffs = append(diffs, fmt.Sprintf("writes: %s != %s", formatWrites(r.Writes), formatWrites(o.Writes)))
+	}
+	return diffs
+}
+
+func equalWrites(a, b []MemWrite) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func formatWrites(writes []MemWrite) string {
+	if len(writes) == 0 {
+		return "none"
+	}
+	var s []string
+	for _, m := range writes {
+		s = append(s, fmt.Sprintf("[%04X]=%02X", m.Addr, m.Value))
+	}
+	return strings.Join(s, " ")
+}
+
+// writeBinary writes a record after the magic and version of the file: the cycles since the previous record as a
+// uvarint, pc, opcode, i, sp, dt, st, a mask of the changed registers followed by their values, the operand of
+// F000, and the number of memory writes as a uvarint followed by their address and value. Words are big endian.
+func writeBinary(w io.Writer, r *TraceRecord, prevCycle uint64) error {
+	buf := make([]byte, 0, 64)
+	buf = appendUvarint(buf, r.Cycle-prevCycle)
+	buf = append(buf, byte(r.PC>>8), byte(r.PC), byte(r.Opcode>>8), byte(r.Opcode), byte(r.I>>8), byte(r.I), r.SP, r.DT, r.ST)
+	buf = append(buf, byte(r.Changed>>8), byte(r.Changed))
+	for x := range r.V {
+		if r.Changed&(1<<x) != 0 {
+			buf = append(buf, r.V[x])
+		}
+	}
+	if r.Opcode == 0xF000 {
+		buf = append(buf, byte(r.Long>>8), byte(r.Long))
+	}
+	buf = appendUvarint(buf, uint64(len(r.Writes)))
+	for _, m := range r.Writes {
+		buf = append(buf, byte(m.Addr>>8), byte(m.Addr), m.Value)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+// TraceReader reads a trace in either format, the registers of the records are complete: the changes are applied
+// to the registers of the previous record.
+type TraceReader struct {
+	r      *bufio.Reader
+	format TraceFormat
+	prev   TraceRecord
+	line   int
+}
+
+// NewTraceReader detects the format of the trace from its first bytes.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	t := &TraceReader{r: bufio.NewReader(r)}
+	magic, err := t.r.Peek(len(traceMagic) + 1)
+	if err == nil && string(magic[:len(traceMagic)]) == traceMagic {
+		if magic[len(traceMagic)] != traceVersion {
+			return nil, InvalidTraceError{Msg: fmt.Sprintf("unknown version %d", magic[len(traceMagic)])}
+		}
+		t.format = TraceBinary
+		t.r.Discard(len(magic))
+	}
+	return t, nil
+}
+
+func (t *TraceReader) Format() TraceFormat {
+	return t.format
+}
+
+// Next returns the next record, io.EOF at the end of the trace.
+func (t *TraceReader) Next() (TraceRecord, error) {
+	var r TraceRecord
+	var err error
+	if t.format == TraceBinary {
+		r, err = t.nextBinary()
+	} else {
+		r, err = t.nextText()
+	}
+	if err != nil {
+		return TraceRecord{}, err
+	}
+	t.prev = r
+	return r, nil
+}
+
+func (t *TraceReader) nextText() (TraceRecord, error) {
+	for {
+		line, err := t.r.ReadString('\n')
+		if line == "" && err != nil {
+			return TraceRecord{}, err
+		}
+		t.line++
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, ok := t.parseLine(line)
+		if !ok {
+			return TraceRecord{}, InvalidTraceError{Line: t.line, Msg: line}
+		}
+		return r, nil
+	}
+}
+
+func (t *TraceReader) parseLine(line string) (TraceRecord, bool) {
+	r := TraceRecord{V: t.prev.V}
+	if i := strings.Index(line, ";"); i >= 0 {
+		r.Text = strings.TrimSpace(line[i+1:])
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return r, false
+	}
+	var err error
+	if r.Cycle, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return r, false
+	}
+	if r.PC, err = parseHex(fields[1]); err != nil {
+		return r, false
+	}
+	opcode := fields[2]
+	if len(opcode) == 8 {
+		if r.Long, err = parseHex(opcode[4:]); err != nil {
+			return r, false
+		}
+		opcode = opcode[:4]
+	}
+	if r.Opcode, err = parseHex(opcode); err != nil {
+		return r, false
+	}
+	for _, f := range fields[3:] {
+		eq := strings.Index(f, "=")
+		if eq < 0 {
+			return r, false
+		}
+		key, value := strings.ToLower(f[:eq]), f[eq+1:]
+		n, err := parseHex(value)
+		if err != nil {
+			return r, false
+		}
+		switch {
+		case key == "i":
+			r.I = n
+		case key == "sp":
+			r.SP = byte(n)
+		case key == "dt":
+			r.DT = byte(n)
+		case key == "st":
+			r.ST = byte(n)
+		case len(key) == 2 && key[0] == 'v':
+			x, err := strconv.ParseUint(key[1:], 16, 8)
+			if err != nil {
+				return r, false
+			}
+			r.V[x] = byte(n)
+			r.Changed |= 1 << x
+		case strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]"):
+			addr, err := parseHex(key[1 : len(key)-1])
+			if err != nil {
+				return r, false
+			}
+			r.Writes = append(r.Writes, MemWrite{Addr: addr, Value: byte(n)})
+		default:
+			return r, false
+		}
+	}
+	return r, true
+}
+
+func (t *TraceReader) nextBinary() (TraceRecord, error) {
+	delta, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return TraceRecord{}, err // io.EOF between records
+	}
+	r := TraceRecord{Cycle: t.prev.Cycle + delta, V: t.prev.V}
+	var fixed [11]byte
+	if _, err := io.ReadFull(t.r, fixed[:]); err != nil {
+		return TraceRecord{}, truncated(err)
+	}
+	r.PC = uint16(fixed[0])<<8 | uint16(fixed[1])
+	r.Opcode = uint16(fixed[2])<<8 | uint16(fixed[3])
+	r.I = uint16(fixed[4])<<8 | uint16(fixed[5])
+	r.SP, r.DT, r.ST = fixed[6], fixed[7], fixed[8]
+	r.Changed = uint16(fixed[9])<<8 | uint16(fixed[10])
+	for x := range r.V {
+		if r.Changed&(1<<x) != 0 {
+			if r.V[x], err = t.r.ReadByte(); err != nil {
+				return TraceRecord{}, truncated(err)
+			}
+		}
+	}
+	if r.Opcode == 0xF000 {
+		var long [2]byte
+		if _, err := io.ReadFull(t.r, long[:]); err != nil {
+			return TraceRecord{}, truncated(err)
+		}
+		r.Long = uint16(long[0])<<8 | uint16(long[1])
+	}
+	n, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return TraceRecord{}, truncated(err)
+	}
+	for ; n > 0; n-- {
+		var m [3]byte
+		if _, err := io.ReadFull(t.r, m[:]); err != nil {
+			return TraceRecord{}, truncated(err)
+		}
+		r.Writes = append(r.Writes, MemWrite{Addr: uint16(m[0])<<8 | uint16(m[1]), Value: m[2]})
+	}
+	in := Disassemble(r.Opcode, r.PC)
+	in.Long = r.Long
+	r.Text = in.String()
+	return r, nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return InvalidTraceError{Msg: "truncated record"}
+	}
+	return err
+}
+
+// ReadTraceFile reads all records of a trace file.
+func ReadTraceFile(file string) ([]TraceRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := NewTraceReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	var records []TraceRecord
+	for {
+		r, err := t.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		records = append(records, r)
+	}
+}
+
+// TraceDivergence is the first record two traces differ in with the records around it.
+type TraceDivergence struct {
+	Record int              // number of the record, starting at 1
+	Diffs  []string         // differences of the records, see TraceRecord.Diff, nil when a trace ended
+	Short  int              // the trace that ended before the record, 0 or 1, -1 when both have it
+	Before []TraceRecord    // the last records both traces agree on
+	After  [2][]TraceRecord // the records of each trace from the divergence on
+	End    [2]bool          // whether the trace ends after the records of After
+}
+
+// CompareTraces compares two traces record by record and returns the number of records they agree on and the first
+// divergence with up to context records before and after it, nil when the traces are identical. The timers are only
+// compared when timers is set.
+func CompareTraces(a, b *TraceReader, context int, timers bool) (int, *TraceDivergence, error) {
+	traces := [2]*TraceReader{a, b}
+	var before []TraceRecord
+	for n := 0; ; n++ {
+		var records [2]TraceRecord
+		var ended [2]bool
+		for i, t := range traces {
+			r, err := t.Next()
+			if err != nil && err != io.EOF {
+				return n, nil, TraceReadError{Trace: i, Err: err}
+			}
+			records[i], ended[i] = r, err == io.EOF
+		}
+		short := -1
+		var diffs []string
+		switch {
+		case ended[0] && ended[1]:
+			return n, nil, nil
+		case ended[0]:
+			short = 0
+		case ended[1]:
+			short = 1
+		default:
+			diffs = records[0].Diff(records[1], timers)
+		}
+		if short < 0 && len(diffs) == 0 {
+			before = append(before, records[0])
+			if len(before) > context {
+				before = before[1:]
+			}
+			continue
+		}
+		d := &TraceDivergence{Record: n + 1, Diffs: diffs, Short: short, Before: before}
+		for i, t := range traces {
+			if ended[i] {
+				d.End[i] = true
+				continue
+			}
+			r := records[i]
+			var err error
+			for j := 0; j <= context && err == nil; j++ {
+				d.After[i] = append(d.After[i], r)
+				r, err = t.Next()
+			}
+			if err != nil && err != io.EOF {
+				return n, nil, TraceReadError{Trace: i, Err: err}
+			}
+			d.End[i] = err == io.EOF
+		}
+		return n, d, nil
+	}
+}
+
+// ParseTraceRange parses an inclusive hexadecimal address range, e.g. 200-2FF.
+func ParseTraceRange(s string) (from uint16, to uint16, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, InvalidTraceRangeError{Range: s}
+	}
+	if from, err = parseHex(parts[0]); err != nil {
+		return 0, 0, InvalidTraceRangeError{Range: s}
+	}
+	if to, err = parseHex(parts[1]); err != nil || to < from {
+		return 0, 0, InvalidTraceRangeError{Range: s}
+	}
+	return from, to, nil
+}
+
+// TraceFile returns the trace file used when tracing is toggled on without a file.
+func TraceFile(rom string) string {
+	return strings.TrimSuffix(rom, filepath.Ext(rom)) + ".trace"
+}
+
+// tracer writes the instructions whose address is in the range to the trace file while it is active, the file stays
+// open when it is toggled off so toggling it on again appends to it.
+type tracer struct {
+	file   string
+	format TraceFormat
+	from   uint16
+	to     uint16
+	out    *os.File
+	w      *bufio.Writer
+	active bool
+	prev   TraceRecord // last written record, the changed registers and the cycle gap are relative to it
+	writes []MemWrite  // memory written by the instruction being executed
+}
+
+// StartTrace writes the executed instructions to the file, a file that is already being traced to is appended to.
+func (c *Chip8) StartTrace(file string, format TraceFormat) error {
+	var err error
+	c.paused(func() {
+		t := &c.trace
+		if t.out != nil && t.file == file && t.format == format {
+			t.active = true
+			return
+		}
+		if err = t.close(); err != nil {
+			return
+		}
+		var out *os.File
+		if out, err = os.Create(file); err != nil {
+			return
+		}
+		t.out, t.w = out, bufio.NewWriter(out)
+		t.file, t.format, t.prev = file, format, TraceRecord{}
+		if format == TraceBinary {
+			_, err = t.w.Write(append([]byte(traceMagic), traceVersion))
+		} else {
+			_, err = fmt.Fprintln(t.w, traceTextHeader)
+		}
+		t.active = err == nil
+	})
+	return err
+}
+
+// StopTrace stops tracing and flushes the trace file.
+func (c *Chip8) StopTrace() error {
+	var err error
+	c.paused(func() {
+		c.trace.active = false
+		if c.trace.w != nil {
+			err = c.trace.w.Flush()
+		}
+	})
+	return err
+}
+
+// CloseTrace stops tracing and closes the trace file.
+func (c *Chip8) CloseTrace() error {
+	var err error
+	c.paused(func() {
+		c.trace.active = false
+		err = c.trace.close()
+	})
+	return err
+}
+
+// SetTraceRange only traces instructions with an address from from to to, both included.
+func (c *Chip8) SetTraceRange(from, to uint16) {
+	c.paused(func() { c.trace.from, c.trace.to = from, to })
+}
+
+// SetTraceFormat sets the format used when tracing is toggled on without a trace file.
+func (c *Chip8) SetTraceFormat(format TraceFormat) {
+	c.paused(func() {
+		if c.trace.out == nil {
+			c.trace.format = format
+		}
+	})
+}
+
+// Tracing reports whether instructions are being traced and the file they are written to, tracing stops by itself
+// when the trace file can not be written.
+func (c *Chip8) Tracing() (active bool, file string) {
+	c.paused(func() { active, file = c.trace.active, c.trace.file })
+	return active, file
+}
+
+// ToggleTrace starts or stops tracing, the trace is written to the last trace file or next to the rom.
+func (c *Chip8) ToggleTrace() {
+	if active, file := c.Tracing(); active {
+		c.report(c.StopTrace(), "stopped tracing to "+file)
+		return
+	}
+	file := c.trace.file
+	if file == "" {
+		file = TraceFile(c.file)
+	}
+	c.report(c.StartTrace(file, c.trace.format), "tracing to "+file)
+}
+
+func (c *Chip8) report(err error, msg string) {
+	if err != nil {
+		msg = err.Error()
+	}
+	if c.setMessage != nil {
+		c.setMessage(msg)
+	}
+}
+
+func (t *tracer) close() error {
+	if t.out == nil {
+		return nil
+	}
+	err := t.w.Flush()
+	if cerr := t.out.Close(); err == nil {
+		err = cerr
+	}
+	t.out, t.w = nil, nil
+	return err
+}
+
+// traceInstruction records the instruction at pc that was just executed.
+func (c *Chip8) traceInstruction(pc uint16) {
+	t := &c.trace
+	if pc < t.from || pc > t.to {
+		t.writes = t.writes[:0]
+		return
+	}
+	in := c.variant.Disassemble(c.opcode, pc)
+	if in.Size() == 4 {
+		in.Long = wordAt(c.memory[:], int(pc)+2)
+	}
+	r := TraceRecord{
+		Cycle: c.cycle, PC: pc, Opcode: c.opcode, Long: in.Long, I: c.i, SP: c.sp, DT: c.delayTimer, ST: c.soundTimer,
+		V: c.v, Writes: t.writes, Text: in.String(),
+	}
+	for x := range r.V {
+		if r.V[x] != t.prev.V[x] {
+			r.Changed |= 1 << x
+		}
+	}
+	var err error
+	if t.format == TraceBinary {
+		err = writeBinary(t.w, &r, t.prev.Cycle)
+	} else {
+		err = writeText(t.w, &r)
+	}
+	r.Writes = nil
+	t.prev = r
+	t.writes = t.writes[:0]
+	if err != nil {
+		t.active = false
+		c.report(err, "")
+	}
+}
@@ -0,0 +1,250 @@
+package chip8
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// traceRecords are records of a trace, the registers of each record are the ones of the previous record with the
+// changed registers applied like a trace reader returns them.
+func traceRecords() []TraceRecord {
+	records := []TraceRecord{
+		{Cycle: 1, PC: 0x200, Opcode: 0x6A2A, Changed: 1 << 0xA},
+		{Cycle: 5, PC: 0x202, Opcode: 0xF000, Long: 0x1234, I: 0x1234, Changed: 1<<0 | 1<<0xF},
+		{Cycle: 6, PC: 0x206, Opcode: 0xF333, I: 0x300, SP: 1, DT: 3, ST: 0xFF,
+			Writes: []MemWrite{{0x300, 0}, {0x301, 4}, {0x302, 2}}},
+	}
+	records[0].V[0xA] = 0x2A
+	records[1].V, records[1].V[0], records[1].V[0xF] = records[0].V, 0x80, 1
+	records[2].V = records[1].V
+	for i, r := range records {
+		in := Disassemble(r.Opcode, r.PC)
+		in.Long = r.Long
+		records[i].Text = in.String()
+	}
+	return records
+}
+
+func readTrace(t *testing.T, r io.Reader, format TraceFormat) []TraceRecord {
+	t.Helper()
+	tr, err := NewTraceReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Format() != format {
+		t.Fatalf("trace read as %s, want %s", tr.Format(), format)
+	}
+	var records []TraceRecord
+	for {
+		r, err := tr.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+}
+
+func TestTextTraceRoundTrip(t *testing.T) {
+	want := traceRecords()
+	var buf bytes.Buffer
+	buf.WriteString(traceTextHeader + "\n")
+	for i := range want {
+		if err := writeText(&buf, &want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readTrace(t, &buf, TraceText); !reflect.DeepEqual(got, want) {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+}
+
+func TestBinaryTraceRoundTrip(t *testing.T) {
+	want := traceRecords()
+	buf := bytes.NewBuffer(append([]byte(traceMagic), traceVersion))
+	var prev uint64
+	for i := range want {
+		if err := writeBinary(buf, &want[i], prev); err != nil {
+			t.Fatal(err)
+		}
+		prev = want[i].Cycle
+	}
+	data := buf.Bytes()
+	if got := readTrace(t, bytes.NewReader(data), TraceBinary); !reflect.DeepEqual(got, want) {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+	if _, err := ReadTraceFile(writeROM(t, "cut.trace", data[:len(data)-1])); !errors.As(err, new(InvalidTraceError)) {
+		t.Errorf("a truncated trace: %v, want an invalid trace", err)
+	}
+}
+
+func TestParseTraceLine(t *testing.T) {
+	for _, line := range []string{
+		"1 0200",
+		"x 0200 6A2A",
+		"1 0200 6A2A vg=01",
+		"1 0200 6A2A i=XYZ",
+		"1 0200 6A2A pc",
+		"1 0200 6A2A [0300=01",
+	} {
+		var tr TraceReader
+		if _, ok := tr.parseLine(line); ok {
+			t.Errorf("%q was parsed", line)
+		}
+	}
+}
+
+// TestTraceRange traces a loop over two subroutines, only the instructions of the range are written.
+func TestTraceRange(t *testing.T) {
+	for _, format := range []TraceFormat{TraceText, TraceBinary} {
+		// 200: call 206, call 20A, jump 200; 206: v0 += 1, return; 20A: v1 += 1, return
+		c := newTestChip(t, Quirks{}, 0x22, 0x06, 0x22, 0x0A, 0x12, 0x00, 0x70, 0x01, 0x00, 0xEE, 0x71, 0x01, 0x00, 0xEE)
+		file := filepath.Join(t.TempDir(), "range.trace")
+		c.SetTraceRange(0x206, 0x209)
+		if err := c.StartTrace(file, format); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 14; i++ {
+			mustStep(t, c)
+		}
+		if err := c.CloseTrace(); err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadTraceFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("%s: %d records, want 2 runs of the subroutine at 206", format, len(records))
+		}
+		for i, r := range records {
+			if want := uint16(0x206 + i%2*2); r.PC != want {
+				t.Errorf("%s: record %d at %03X, want %03X", format, i, r.PC, want)
+			}
+		}
+		// V1 changed outside of the range since the previous record
+		if r := records[2]; r.Cycle != 9 || r.V[0] != 2 || r.V[1] != 1 || r.Changed != 0x3 {
+			t.Errorf("%s: second v0 += 1 at cycle %d with V0=%d V1=%d changed %04X", format, r.Cycle, r.V[0], r.V[1], r.Changed)
+		}
+	}
+}
+
+// TestTraceWriteError traces to a full device while the rom runs, tracing stops when the trace can not be written.
+func TestTraceWriteError(t *testing.T) {
+	c := newTestChip(t, Quirks{}, loop...)
+	if err := c.StartTrace("/dev/full", TraceText); err != nil {
+		t.Skip(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	c.run()
+	for {
+		if active, _ := c.Tracing(); !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tracing to a full device did not stop")
+		}
+		time.Sleep(frameRate)
+	}
+	c.halt()
+	c.CloseTrace()
+}
+
+// textTrace returns a reader of a text trace of the records.
+func textTrace(t *testing.T, records []TraceRecord) *TraceReader {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := range records {
+		if err := writeText(&buf, &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr, err := NewTraceReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestCompareTraces(t *testing.T) {
+	// a counting loop of 8 records, every record sets V0 to its cycle
+	trace := func() []TraceRecord {
+		var records []TraceRecord
+		for n := 1; n <= 8; n++ {
+			r := TraceRecord{Cycle: uint64(n), PC: 0x200, Opcode: 0x7001, Changed: 1, I: 0x300}
+			r.V[0] = byte(n)
+			records = append(records, r)
+		}
+		return records
+	}
+	for _, tc := range []struct {
+		name   string
+		change func(b []TraceRecord) []TraceRecord
+		record int
+		diffs  []string
+		short  int
+	}{
+		{"identical", func(b []TraceRecord) []TraceRecord { return b }, 0, nil, -1},
+		{"pc", func(b []TraceRecord) []TraceRecord { b[4].PC = 0x202; return b }, 5, []string{"pc: 0200 != 0202"}, -1},
+		{"register", func(b []TraceRecord) []TraceRecord {
+			for i := 5; i < len(b); i++ {
+				b[i].V[0]++
+			}
+			return b
+		}, 6, []string{"v0: 06 != 07"}, -1},
+		{"i", func(b []TraceRecord) []TraceRecord { b[0].I = 0x301; return b }, 1, []string{"i: 0300 != 0301"}, -1},
+		{"memory write", func(b []TraceRecord) []TraceRecord {
+			b[6].Writes = []MemWrite{{0x300, 7}}
+			return b
+		}, 7, []string{"writes: none != [0300]=07"}, -1},
+		{"b ends early", func(b []TraceRecord) []TraceRecord { return b[:3] }, 4, nil, 1},
+		{"a ends early", func(b []TraceRecord) []TraceRecord {
+			r := b[7]
+			r.Cycle = 9
+			return append(b, r)
+		}, 9, nil, 0},
+	} {
+		n, d, err := CompareTraces(textTrace(t, trace()), textTrace(t, tc.change(trace())), 2, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if tc.record == 0 {
+			if d != nil || n != 8 {
+				t.Errorf("%s: %d records agree, divergence %+v", tc.name, n, d)
+			}
+			continue
+		}
+		if d == nil {
+			t.Errorf("%s: the traces do not diverge", tc.name)
+			continue
+		}
+		if n != tc.record-1 || d.Record != tc.record || d.Short != tc.short || !reflect.DeepEqual(d.Diffs, tc.diffs) {
+			t.Errorf("%s: %d records agree, divergence at %d with %q, trace %d ended", tc.name, n, d.Record, d.Diffs, d.Short)
+		}
+		want := tc.record - 1
+		if want > 2 {
+			want = 2
+		}
+		if len(d.Before) != want || want > 0 && d.Before[want-1].Cycle != uint64(tc.record-1) {
+			t.Errorf("%s: %d records before the divergence, want %d up to cycle %d", tc.name, len(d.Before), want, tc.record-1)
+		}
+		for i, after := range d.After {
+			if i == d.Short {
+				if len(after) != 0 || !d.End[i] {
+					t.Errorf("%s: trace %d ended with %d records after the divergence", tc.name, i, len(after))
+				}
+				continue
+			}
+			if len(after) == 0 || after[0].Cycle != uint64(tc.record) || len(after) > 3 || d.End[i] != (after[len(after)-1].Cycle >= 8) {
+				t.Errorf("%s: trace %d has %d records from the divergence on, end %t", tc.name, i, len(after), d.End[i])
+			}
+		}
+	}
+}
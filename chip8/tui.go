@@ -2,6 +2,7 @@ package chip8
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/MickLuypaerts/chip8Emu/emulator"
 )
@@ -24,20 +25,48 @@ func (c Chip8) GetStackValues() []string {
 	var stack []string
 
 	for i := range c.stack {
-		stack = append(stack, fmt.Sprintf("%X: 0x%04X", i, c.stack[i]))
+		entry := fmt.Sprintf("%X: 0x%04X", i, c.stack[i])
+		if label := c.symbols.Describe(c.stack[i]); label != "" && i < int(c.sp) {
+			entry += " " + label
+		}
+		stack = append(stack, entry)
 	}
 	stack = append(stack, fmt.Sprintf("SP: %X", c.sp))
 	return stack
 }
 
 func (c Chip8) GetMemoryValues() []byte {
-	return c.memory[:]
+	return c.memory[:c.memSize]
 }
 
 func (c Chip8) GetScreenSize() (int, int) {
-	return screenWidth, screenHeigth
+	return c.width, c.height
 }
 
+// EmulatorInfo is the instruction executed last, it is only disassembled when the INFO panel is updated.
 func (c Chip8) EmulatorInfo() emulator.EmulatorInfo {
-	return c.info
+	info := emulator.CreateEmulatorInfo(0, "", "", "", c.pc)
+	if c.cycle > 0 {
+		in := c.variant.Disassemble(c.opcode, c.opcodeAddr)
+		info = emulator.CreateEmulatorInfo(c.opcode, in.Name, in.Type, in.Desc, c.pc)
+	}
+	return info.WithState(c.status()).WithSpeed(c.speed, c.InstructionsPerFrame()).WithLabel(c.symbols.Describe(c.pc))
+}
+
+// ROMName is the title and authors of the rom when it is in the rom database, else the name of the rom file.
+func (c Chip8) ROMName() string {
+	if c.romInfo != nil && c.romInfo.Title != "" {
+		return c.romInfo.Name()
+	}
+	return filepath.Base(c.file)
+}
+
+// Disassemble decodes the instruction at addr in a copy of the memory, it only reads the variant, the labels and
+// the breakpoints of the machine so the TUI can disassemble while the rom runs.
+func (c *Chip8) Disassemble(memory []byte, addr uint16) emulator.DisasmLine {
+	in := c.variant.DisassembleAt(memory, addr)
+	target, ok := in.Target()
+	_, breakpoint := c.debug.breakpoints[addr]
+	label, _ := c.symbols.Name(addr)
+	return emulator.DisasmLine{Addr: addr, Size: in.Size(), Text: in.FormatSymbols(SyntaxOcto, c.symbols), Label: label, Target: target, HasTarget: ok, Breakpoint: breakpoint}
 }
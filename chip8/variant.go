@@ -0,0 +1,48 @@
+package chip8
+
+import "strings"
+
+// Variant selects the machine the decoder emulates, extended opcodes are only decoded by the variants that have them.
+type Variant int
+
+const (
+	VariantChip8 Variant = iota
+	VariantSChip
+	VariantXOChip
+)
+
+var variantNames = map[Variant]string{
+	VariantChip8:  "chip8",
+	VariantSChip:  "schip",
+	VariantXOChip: "xochip",
+}
+
+func (v Variant) String() string {
+	return variantNames[v]
+}
+
+// DefaultQuirks is the quirks preset roms written for the variant expect.
+func (v Variant) DefaultQuirks() string {
+	if v == VariantSChip {
+		return "schip"
+	}
+	return DefaultQuirks
+}
+
+// hasResolution reports whether the screen of the variant can be width x height pixels.
+func (v Variant) hasResolution(width, height int) bool {
+	return width == screenWidth && height == screenHeigth || v >= VariantSChip && width == hiresScreenWidth && height == hiresScreenHeigth
+}
+
+func ParseVariant(s string) (Variant, error) {
+	for v, name := range variantNames {
+		if strings.EqualFold(name, s) {
+			return v, nil
+		}
+	}
+	return VariantChip8, UnknownVariantError{Name: s, Valid: VariantNames()}
+}
+
+func VariantNames() []string {
+	return []string{VariantChip8.String(), VariantSChip.String(), VariantXOChip.String()}
+}
@@ -0,0 +1,242 @@
+// Package config reads the configuration file of the emulator, settings that apply to every rom and overrides for
+// single roms.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+// Config holds the settings of the configuration file, zero values leave the defaults unchanged.
+type Config struct {
+	Settings
+	ROMs map[string]Settings `json:"roms"` // overrides by rom file name or sha1
+}
+
+// Settings are the settings of the configuration file, they apply like the command line flags of the same name.
+type Settings struct {
+	QuitKey  string          `json:"quitKey"`
+	Keys     emulator.KeyMap `json:"keys"` // moves controls to other keys, {"<F9>": "<F12>"}
+	Variant  string          `json:"variant"`
+	Quirks   string          `json:"quirks"`
+	Hz       int             `json:"hz"`
+	IPF      int             `json:"ipf"`
+	Palette  []string        `json:"palette"` // #RRGGBB colors, background first
+	LoadAddr string          `json:"loadAddr"`
+	OnError  string          `json:"onError"`
+	Rewind   *int            `json:"rewind"`
+	Keypad   string          `json:"keypad"`
+}
+
+// File is the configuration file in the user config directory, $XDG_CONFIG_HOME/chip8/config.json on Linux.
+func File() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chip8", "config.json"), nil
+}
+
+// Parse reads and validates a configuration, unknown settings are errors.
+func Parse(r io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, syntaxError(data, dec, err)
+	}
+	if err := c.Settings.validate(""); err != nil {
+		return nil, err
+	}
+	for _, rom := range sortedROMs(c.ROMs) {
+		if err := c.ROMs[rom].validate(fmt.Sprintf("roms[%q].", rom)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// syntaxError adds the line and column of the error to a decoding error.
+func syntaxError(data []byte, dec *json.Decoder, err error) error {
+	offset := dec.InputOffset()
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		offset = syntax.Offset
+	case errors.As(err, &typ):
+		offset = typ.Offset
+		err = fmt.Errorf("%s: expected %s, got %s", typ.Field, typ.Type, typ.Value)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return SyntaxError{Line: line, Column: column, Err: err}
+}
+
+func (s Settings) validate(prefix string) error {
+	invalid := func(setting string, err error) error {
+		return InvalidSettingError{Setting: prefix + setting, Err: err}
+	}
+	if s.Variant != "" {
+		if _, err := chip8.ParseVariant(s.Variant); err != nil {
+			return invalid("variant", err)
+		}
+	}
+	if s.Quirks != "" {
+		if _, err := chip8.ParseQuirks(s.Quirks); err != nil {
+			return invalid("quirks", err)
+		}
+	}
+	if s.Hz < 0 {
+		return invalid("hz", RangeError{Value: s.Hz})
+	}
+	if s.IPF < 0 {
+		return invalid("ipf", RangeError{Value: s.IPF})
+	}
+	if s.Rewind != nil && *s.Rewind < 0 {
+		return invalid("rewind", RangeError{Value: *s.Rewind})
+	}
+	if s.Palette != nil {
+		if _, err := chip8.ParsePalette(s.Palette); err != nil {
+			return invalid("palette", err)
+		}
+	}
+	if s.OnError != "" {
+		if _, err := chip8.ParseErrorPolicy(s.OnError); err != nil {
+			return invalid("onError", err)
+		}
+	}
+	if s.Keypad != "" {
+		if _, err := chip8.ParseKeypadLayout(s.Keypad); err != nil {
+			return invalid("keypad", err)
+		}
+	}
+	to := make(map[string]string)
+	for _, from := range sortedKeys(s.Keys) {
+		if s.Keys[from] == "" {
+			return invalid(fmt.Sprintf("keys[%q]", from), EmptyKeyError{})
+		}
+		if other, ok := to[s.Keys[from]]; ok {
+			return invalid(fmt.Sprintf("keys[%q]", from), fmt.Errorf("%w, %s is moved there too", emulator.DoubleKeyAssigmentError{Key: s.Keys[from]}, other))
+		}
+		to[s.Keys[from]] = from
+	}
+	return nil
+}
+
+// Read reads a configuration file.
+func Read(file string) (*Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return c, nil
+}
+
+// Load reads the configuration file in the user config directory, an empty configuration when there is none.
+func Load() (*Config, error) {
+	file, err := File()
+	if err != nil {
+		return new(Config), nil
+	}
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return new(Config), nil
+	}
+	return Read(file)
+}
+
+// ForROM returns the settings for a rom, the overrides for its file name and then for its sha1 replace the
+// settings they set.
+func (c *Config) ForROM(file string, hash string) Settings {
+	s := c.Settings
+	for _, key := range []string{filepath.Base(file), strings.ToLower(hash)} {
+		if o, ok := c.ROMs[key]; ok {
+			s = s.merge(o)
+		}
+	}
+	return s
+}
+
+func (s Settings) merge(o Settings) Settings {
+	if o.QuitKey != "" {
+		s.QuitKey = o.QuitKey
+	}
+	if o.Keys != nil {
+		keys := make(emulator.KeyMap)
+		for from, to := range s.Keys {
+			keys[from] = to
+		}
+		for from, to := range o.Keys {
+			keys[from] = to
+		}
+		s.Keys = keys
+	}
+	if o.Variant != "" {
+		s.Variant = o.Variant
+	}
+	if o.Quirks != "" {
+		s.Quirks = o.Quirks
+	}
+	if o.Hz != 0 || o.IPF != 0 {
+		s.Hz, s.IPF = o.Hz, o.IPF
+	}
+	if o.Palette != nil {
+		s.Palette = o.Palette
+	}
+	if o.LoadAddr != "" {
+		s.LoadAddr = o.LoadAddr
+	}
+	if o.OnError != "" {
+		s.OnError = o.OnError
+	}
+	if o.Rewind != nil {
+		s.Rewind = o.Rewind
+	}
+	if o.Keypad != "" {
+		s.Keypad = o.Keypad
+	}
+	return s
+}
+
+func sortedROMs(m map[string]Settings) []string {
+	var roms []string
+	for rom := range m {
+		roms = append(roms, rom)
+	}
+	sort.Strings(roms)
+	return roms
+}
+
+func sortedKeys(m emulator.KeyMap) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
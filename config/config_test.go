@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(`{
+		"quitKey": "q",
+		"keys": {"<F9>": "<F12>"},
+		"variant": "schip",
+		"quirks": "modern,shift",
+		"hz": 1000,
+		"palette": ["#000", "#FFFFFF"],
+		"rewind": 0,
+		"keypad": "azerty",
+		"roms": {"pong.ch8": {"ipf": 30, "onError": "log"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewind := 0
+	want := Settings{QuitKey: "q", Keys: emulator.KeyMap{"<F9>": "<F12>"}, Variant: "schip", Quirks: "modern,shift",
+		Hz: 1000, Palette: []string{"#000", "#FFFFFF"}, Rewind: &rewind, Keypad: "azerty"}
+	if !reflect.DeepEqual(c.Settings, want) {
+		t.Errorf("settings %+v, want %+v", c.Settings, want)
+	}
+	if got, want := c.ROMs["pong.ch8"], (Settings{IPF: 30, OnError: "log"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("pong.ch8 settings %+v, want %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		json    string
+		setting string // the invalid setting, empty for syntax errors
+		err     error  // an error the error wraps
+	}{
+		{`{"hz": 1000,}`, "", nil},
+		{"{\n  \"hz\": \"fast\"\n}", "", nil},
+		{`{"speed": 1000}`, "", nil},
+		{`{"variant": "chip9"}`, "variant", chip8.UnknownVariantError{}},
+		{`{"quirks": "modern,nojump"}`, "quirks", nil},
+		{`{"hz": -1}`, "hz", RangeError{Value: -1}},
+		{`{"rewind": -5}`, "rewind", RangeError{Value: -5}},
+		{`{"palette": ["#000"]}`, "palette", chip8.PaletteSizeError{Size: 1}},
+		{`{"palette": ["#000", "#1", "#2", "#3", "#4"]}`, "palette", chip8.PaletteSizeError{Size: 5}},
+		{`{"palette": ["#000", "red"]}`, "palette", chip8.InvalidColorError{Color: "red"}},
+		{`{"palette": ["#000", "#GGGGGG"]}`, "palette", chip8.InvalidColorError{Color: "#GGGGGG"}},
+		{`{"onError": "ignore"}`, "onError", nil},
+		{`{"keypad": "dvorak"}`, "keypad", nil},
+		{`{"keys": {"<F9>": ""}}`, `keys["<F9>"]`, EmptyKeyError{}},
+		{`{"keys": {"<F9>": "<F12>", "<F10>": "<F12>"}}`, `keys["<F9>"]`, emulator.DoubleKeyAssigmentError{Key: "<F12>"}},
+		{`{"roms": {"pong.ch8": {"palette": ["#000", "#12345"]}}}`, `roms["pong.ch8"].palette`, chip8.InvalidColorError{Color: "#12345"}},
+		{`{"roms": {"a.ch8": {}, "b.ch8": {"keys": {"a": "x", "b": "x"}}}}`, `roms["b.ch8"].keys["b"]`, emulator.DoubleKeyAssigmentError{Key: "x"}},
+	} {
+		_, err := Parse(strings.NewReader(tc.json))
+		if err == nil {
+			t.Errorf("%s was parsed", tc.json)
+			continue
+		}
+		if tc.setting == "" {
+			if !errors.As(err, new(SyntaxError)) {
+				t.Errorf("%s: %v, want a syntax error", tc.json, err)
+			}
+			continue
+		}
+		var invalid InvalidSettingError
+		if !errors.As(err, &invalid) || invalid.Setting != tc.setting {
+			t.Errorf("%s: %v, want an invalid %s", tc.json, err, tc.setting)
+		}
+		if tc.err != nil && !errors.As(err, reflect.New(reflect.TypeOf(tc.err)).Interface()) {
+			t.Errorf("%s: %v, want a %T", tc.json, err, tc.err)
+		}
+		if e, ok := tc.err.(RangeError); ok && !errors.Is(err, e) {
+			t.Errorf("%s: %v, want %v", tc.json, err, e)
+		}
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	_, err := Parse(strings.NewReader("{\n  \"hz\": 60,\n  \"ipf\" 10\n}"))
+	var syntax SyntaxError
+	if !errors.As(err, &syntax) || syntax.Line != 3 {
+		t.Errorf("%v, want a syntax error on line 3", err)
+	}
+}
+
+// TestForROM checks that the overrides for the file name and then for the sha1 replace the settings they set.
+func TestForROM(t *testing.T) {
+	c, err := Parse(strings.NewReader(`{
+		"variant": "chip8",
+		"quirks": "vip",
+		"hz": 700,
+		"keys": {"<F9>": "<F12>", "<F5>": "r"},
+		"roms": {
+			"pong.ch8": {"quirks": "modern", "ipf": 20, "keys": {"<F5>": "<F6>"}},
+			"da39a3ee5e6b4b0d3255bfef95601890afd80709": {"variant": "xochip", "quirks": "schip"}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		file, hash string
+		want       Settings
+	}{
+		{"other.ch8", "0000", Settings{Variant: "chip8", Quirks: "vip", Hz: 700, Keys: emulator.KeyMap{"<F9>": "<F12>", "<F5>": "r"}}},
+		{"roms/pong.ch8", "0000", Settings{Variant: "chip8", Quirks: "modern", IPF: 20, Keys: emulator.KeyMap{"<F9>": "<F12>", "<F5>": "<F6>"}}},
+		{"roms/pong.ch8", "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709", Settings{Variant: "xochip", Quirks: "schip", IPF: 20, Keys: emulator.KeyMap{"<F9>": "<F12>", "<F5>": "<F6>"}}},
+	} {
+		if got := c.ForROM(tc.file, tc.hash); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %s: %+v, want %+v", tc.file, tc.hash, got, tc.want)
+		}
+	}
+	if c.Keys["<F5>"] != "r" {
+		t.Errorf("the override changed the global keys: %v", c.Keys)
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import "fmt"
+
+// SyntaxError is a configuration that is not valid JSON or has a setting of the wrong type or name.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("line %d column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+type InvalidSettingError struct {
+	Setting string
+	Err     error
+}
+
+func (e InvalidSettingError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Setting, e.Err)
+}
+
+func (e InvalidSettingError) Unwrap() error {
+	return e.Err
+}
+
+type RangeError struct {
+	Value int
+}
+
+func (e RangeError) Error() string {
+	return fmt.Sprintf("%d is negative", e.Value)
+}
+
+type EmptyKeyError struct{}
+
+func (e EmptyKeyError) Error() string {
+	return "the key is empty"
+}
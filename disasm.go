@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+)
+
+// disasm is the disasm command, it prints the listing of a rom.
+func disasm(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	variant := fs.String("variant", chip8.VariantXOChip.String(), fmt.Sprintf("machine variant whose opcodes are decoded (%s)", strings.Join(chip8.VariantNames(), ", ")))
+	syntax := fs.String("syntax", chip8.SyntaxOcto.String(), fmt.Sprintf("assembly syntax (%s)", strings.Join(chip8.SyntaxNames(), ", ")))
+	addr := fs.Uint("addr", chip8.DefaultLoadAddress, "address the rom is loaded at, 0x600 for ETI-660 roms")
+	sym := fs.String("sym", "", "symbol file whose labels replace addresses (default the .sym file next to the rom if there is one)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 disasm [OPTIONS] FILE|ARCHIVE.zip[:NAME]|CARTRIDGE.gif\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	v, err := chip8.ParseVariant(*variant)
+	if err != nil {
+		return err
+	}
+	s, err := chip8.ParseSyntax(*syntax)
+	if err != nil {
+		return err
+	}
+	rf, err := chip8.OpenROM(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *sym == "" {
+		if _, err := os.Stat(chip8.SymbolsFile(fs.Arg(0))); err == nil {
+			*sym = chip8.SymbolsFile(fs.Arg(0))
+		}
+	}
+	var syms *chip8.Symbols
+	if rf.Labels != nil {
+		syms = chip8.NewSymbols(rf.Labels)
+	}
+	if *sym != "" {
+		if syms, err = chip8.ReadSymbols(*sym); err != nil {
+			return err
+		}
+	}
+	writeListing(os.Stdout, v.Listing(rf.Data, uint16(*addr)), s, syms)
+	return nil
+}
+
+func writeListing(w io.Writer, lines []chip8.Line, s chip8.Syntax, syms *chip8.Symbols) {
+	for _, l := range lines {
+		if name, ok := syms.Name(l.Addr); ok {
+			if s == chip8.SyntaxClassic {
+				fmt.Fprintf(w, "%s:\n", name)
+			} else {
+				fmt.Fprintf(w, ": %s\n", name)
+			}
+		}
+		var raw strings.Builder
+		for _, b := range l.Bytes {
+			fmt.Fprintf(&raw, "%02X ", b)
+		}
+		text := l.Instruction.FormatSymbols(s, syms)
+		if l.Data {
+			text = dataText(l.Bytes, s)
+		}
+		fmt.Fprintf(w, "0x%04X  %-12s %s\n", l.Addr, raw.String(), text)
+	}
+}
+
+// dataText formats bytes that are never executed, Octo writes them as plain numbers.
+func dataText(data []byte, s chip8.Syntax) string {
+	var bytes []string
+	for _, b := range data {
+		bytes = append(bytes, fmt.Sprintf("0x%02X", b))
+	}
+	if s == chip8.SyntaxClassic {
+		return "DB " + strings.Join(bytes, ", ") + " ; data"
+	}
+	return strings.Join(bytes, " ") + " # data"
+}
@@ -0,0 +1,74 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command is a console command, it is called with the words typed after its name and returns the message shown
+// to the user.
+type Command struct {
+	f     func(args []string) (string, error)
+	args  string
+	usage string
+}
+
+func NewCommand(f func(args []string) (string, error), args string, u string) Command {
+	return Command{f: f, args: args, usage: u}
+}
+
+func createCommandMap(commands ...map[string]Command) (map[string]Command, error) {
+	c := make(map[string]Command)
+	for _, m := range commands {
+		for name, command := range m {
+			if _, ok := c[name]; ok {
+				return nil, DoubleCommandError{Name: name}
+			}
+			c[name] = command
+		}
+	}
+	return c, nil
+}
+
+// executeCommand runs a line typed in the console, the result is shown as message.
+func (emu *Emulator) executeCommand(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	command, ok := emu.commands[fields[0]]
+	if !ok {
+		emu.tui.SetMessage(UnknownCommandError{Name: fields[0]}.Error())
+		return
+	}
+	msg, err := command.f(fields[1:])
+	emu.report(err, msg)
+}
+
+// CommandsMap returns the commands handled by the emulator itself.
+func (emu *Emulator) CommandsMap() map[string]Command {
+	m := make(map[string]Command)
+	m["load"] = NewCommand(emu.load, "FILE", "load another rom")
+	return m
+}
+
+func (emu *Emulator) load(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", CommandArgsError{Name: "load", Args: "FILE"}
+	}
+	if err := emu.chip.Load(args[0]); err != nil {
+		return "", err
+	}
+	emu.romFile = args[0]
+	return fmt.Sprintf("loaded %s", args[0]), nil
+}
+
+func commandNames(commands map[string]Command) []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
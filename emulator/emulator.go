@@ -1,12 +1,19 @@
 package emulator
 
 import (
-	"os"
+	"image/color"
+	"sync"
 )
 
 type Chip interface {
 	Init(file string, tuiSetter TUISetter) error
+	Load(file string) error
 	ControlsMap() map[string]Control
+	CommandsMap() map[string]Command
+	ExitSignal() <-chan struct{}
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+	ToggleBreakpoint(addr uint16)
 
 	ChipGetter
 }
@@ -16,100 +23,171 @@ type ChipGetter interface {
 	GetScreenSize() (int, int)
 	GetGPRValues() []string
 	EmulatorInfo() EmulatorInfo
+	ROMName() string
+	KeypadKeys() []string // the host key of every keypad key
 	GetMemoryValues() []byte
+	Disassemble(memory []byte, addr uint16) DisasmLine
 
-	DrawSignal() <-chan []byte
+	DrawSignal() <-chan Frame
 	KeySignal() <-chan []byte
 }
 
 type TUI interface {
-	Init(drawSignal <-chan []byte, keySignal <-chan []byte, c Chip)
+	Init(drawSignal <-chan Frame, keySignal <-chan []byte, c Chip)
 	Close()
 	Render()
 	Setup() error
 	KeyEvent() <-chan string
 	ControlsMap() map[string]Control
+	CommandsMap() map[string]Command
+	SetCommands(names []string)
 
 	TUISetter
 }
 
+// Frame is a copy of the screen published by the chip, every byte is a pixel holding the planes it is set on.
+type Frame struct {
+	Width   int
+	Height  int
+	Pixels  []byte
+	Palette color.Palette // color of each bitplane combination, nil for the colors of the TUI
+}
+
+// DisasmLine is an instruction disassembled for the TUI, jumps and calls have the address they go to as target.
+type DisasmLine struct {
+	Addr       uint16
+	Size       int
+	Text       string
+	Label      string // label of Addr, empty without one
+	Target     uint16
+	HasTarget  bool
+	Breakpoint bool
+}
+
 type TUISetter interface {
 	SetEmuInfo(c ChipGetter)
+	SetMessage(msg string)
 }
 
-var (
-	quitSignal = make(chan struct{})
-)
-
 type Emulator struct {
 	chip     Chip
 	tui      TUI
 	controls map[string]func()
+	commands map[string]Command
+	quit     chan struct{}
+	quitOnce sync.Once
+	romFile  string
 }
 
 func (emu *Emulator) Run() {
 	defer emu.tui.Close()
 	emu.tui.Render()
 
-	go func() {
-		<-quitSignal
-		clearTerminal()
-		os.Exit(0)
-	}()
 	keyEvents := emu.tui.KeyEvent()
 	for {
-		key := <-keyEvents
-		executeKeyFunction(emu.controls, key)
+		select {
+		case <-emu.quit:
+			return
+		case <-emu.chip.ExitSignal():
+			return
+		case key := <-keyEvents:
+			if len(key) > 1 && key[0] == ':' {
+				emu.executeCommand(key[1:])
+			} else {
+				executeKeyFunction(emu.controls, key)
+			}
+		}
 	}
 }
 
-func CreateEmulator(args []string, quitKey string, c Chip, t TUI) (*Emulator, error) {
-	e := new(Emulator)
-	if len(args) < 2 {
-		usage(args[0], c.ControlsMap(), t.ControlsMap())
-		os.Exit(0)
+// KeyMap moves controls from their key to another one, e.g. {"<F9>": "<F12>"}.
+type KeyMap map[string]string
+
+// CreateEmulator loads the rom file in the chip and connects it to the TUI, keys moves controls to other keys.
+func CreateEmulator(file string, quitKey string, keys KeyMap, c Chip, t TUI) (*Emulator, error) {
+	e := &Emulator{quit: make(chan struct{})}
+	if file == "" {
+		return nil, NoROMError{}
+	}
+	controls, err := remapControls(keys, c.ControlsMap(), t.ControlsMap(), e.ControlsMap())
+	if err != nil {
+		return nil, err
+	}
+
+	e.controls, err = createKeyFuncMap(quitKey, e.stop, controls...)
+	if err != nil {
+		return nil, err
+	}
+	e.commands, err = createCommandMap(c.CommandsMap(), t.CommandsMap(), e.CommandsMap())
+	if err != nil {
+		return nil, err
 	}
 
 	e.chip = c
 	e.tui = t
+	e.romFile = file
 	if err := t.Setup(); err != nil {
 		return nil, err
 	}
-
-	err := e.chip.Init(args[1], t)
-	if err != nil {
+	// the terminal is restored when the rom can not be loaded, the caller exits with the error
+	if err := e.chip.Init(file, t); err != nil {
+		t.Close()
 		return nil, err
 	}
 
 	e.tui.Init(c.DrawSignal(), c.KeySignal(), e.chip)
-
-	e.controls, err = createKeyFuncMap(c.ControlsMap(), t.ControlsMap(), quitKey)
-	if err != nil {
-		return nil, err
-	}
+	t.SetCommands(commandNames(e.commands))
 	return e, nil
 }
 
-func createKeyFuncMap(chip map[string]Control, tui map[string]Control, quitKey string) (map[string]func(), error) {
+func createKeyFuncMap(quitKey string, quit func(), controls ...map[string]Control) (map[string]func(), error) {
 	c := make(map[string]func())
-	for k, v := range chip {
-		if _, ok := c[k]; !ok {
-			c[k] = v.f
-		} else {
-			return nil, DoubleKeyAssigmentError{Key: k}
+	for _, m := range controls {
+		for k, v := range m {
+			if _, ok := c[k]; !ok {
+				c[k] = v.f
+			} else {
+				return nil, DoubleKeyAssigmentError{Key: k}
+			}
 		}
 	}
-	for k, v := range tui {
-		if _, ok := c[k]; !ok {
-			c[k] = v.f
-		} else {
-			return nil, DoubleKeyAssigmentError{Key: k}
+	if _, ok := c[quitKey]; ok {
+		return nil, DoubleKeyAssigmentError{Key: quitKey}
+	}
+	c[quitKey] = quit
+	return c, nil
+}
+
+// remapControls moves the controls to the keys of the key map, controls can trade keys with each other.
+func remapControls(keys KeyMap, controls ...map[string]Control) ([]map[string]Control, error) {
+	for from := range keys {
+		found := false
+		for _, m := range controls {
+			_, ok := m[from]
+			found = found || ok
+		}
+		if !found {
+			return nil, UnknownKeyError{Key: from}
 		}
 	}
-	if _, ok := c[quitKey]; !ok {
-		c[quitKey] = func() { close(quitSignal) }
+	remapped := make([]map[string]Control, len(controls))
+	for i, m := range controls {
+		remapped[i] = make(map[string]Control, len(m))
+		for k, c := range m {
+			if to, ok := keys[k]; ok {
+				k = to
+			}
+			if _, ok := remapped[i][k]; ok {
+				return nil, DoubleKeyAssigmentError{Key: k}
+			}
+			remapped[i][k] = c
+		}
 	}
-	return c, nil
+	return remapped, nil
+}
+
+func (emu *Emulator) stop() {
+	emu.quitOnce.Do(func() { close(emu.quit) })
 }
 
 func executeKeyFunction(m map[string]func(), k string) {
@@ -4,18 +4,28 @@ import "fmt"
 
 type EmulatorInfo struct {
 	programCount uint16
+	label        string // label of the PC
 	opcode       uint16
 	opcodeName   string
 	opcodeType   string
 	opcodeDesc   string
+	state        string
+	speed        int
+	ipf          int
 }
 
 func (o EmulatorInfo) String() string {
-	return fmt.Sprintf("PC: %d\n", o.programCount) +
+	pc := fmt.Sprintf("PC: %d\n", o.programCount)
+	if o.label != "" {
+		pc = fmt.Sprintf("PC: %d (%s)\n", o.programCount, o.label)
+	}
+	return pc +
 		fmt.Sprintf("OPCODE: 0x%04X\n", o.opcode) +
 		fmt.Sprintf("Name:     %s\n", o.opcodeName) +
 		fmt.Sprintf("Type: %s\n", o.opcodeType) +
-		fmt.Sprintf("Desc: %s\n", o.opcodeDesc)
+		fmt.Sprintf("Desc: %s\n", o.opcodeDesc) +
+		fmt.Sprintf("State: %s\n", o.state) +
+		fmt.Sprintf("Speed: %d Hz (%d/frame)\n", o.speed, o.ipf)
 }
 
 func CreateEmulatorInfo(o uint16, n string, t string, d string, pc uint16) EmulatorInfo {
@@ -25,3 +35,22 @@ func CreateEmulatorInfo(o uint16, n string, t string, d string, pc uint16) Emula
 func (o EmulatorInfo) ProgramCount() uint16 {
 	return o.programCount
 }
+
+// WithState returns a copy of the info with the cpu state (running, stopped, waiting for a key...) set.
+func (o EmulatorInfo) WithState(s string) EmulatorInfo {
+	o.state = s
+	return o
+}
+
+// WithSpeed returns a copy of the info with the clock speed in instructions per second and per frame set.
+func (o EmulatorInfo) WithSpeed(ips int, ipf int) EmulatorInfo {
+	o.speed = ips
+	o.ipf = ipf
+	return o
+}
+
+// WithLabel returns a copy of the info with the label of the PC set.
+func (o EmulatorInfo) WithLabel(l string) EmulatorInfo {
+	o.label = l
+	return o
+}
@@ -7,3 +7,43 @@ type DoubleKeyAssigmentError struct {
 func (e DoubleKeyAssigmentError) Error() string {
 	return "double assignment of key: " + e.Key
 }
+
+type NoROMError struct{}
+
+func (e NoROMError) Error() string {
+	return "no rom file given"
+}
+
+type UnknownKeyError struct {
+	Key string
+}
+
+func (e UnknownKeyError) Error() string {
+	return "no control is assigned to key: " + e.Key
+}
+
+type DoubleCommandError struct {
+	Name string
+}
+
+func (e DoubleCommandError) Error() string {
+	return "double registration of command: " + e.Name
+}
+
+type UnknownCommandError struct {
+	Name string
+}
+
+func (e UnknownCommandError) Error() string {
+	return "unknown command: " + e.Name
+}
+
+// CommandArgsError is returned by commands called with the wrong arguments.
+type CommandArgsError struct {
+	Name string
+	Args string
+}
+
+func (e CommandArgsError) Error() string {
+	return "usage: " + e.Name + " " + e.Args
+}
@@ -0,0 +1,47 @@
+package emulator
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+const stateSlots = 4
+
+// ControlsMap returns the controls handled by the emulator itself, F1-F4 save and F5-F8 load the state slots.
+func (emu *Emulator) ControlsMap() map[string]Control {
+	m := make(map[string]Control)
+	for slot := 1; slot <= stateSlots; slot++ {
+		slot := slot
+		m[fmt.Sprintf("<F%d>", slot)] = NewControl(func() { emu.saveState(slot) }, fmt.Sprintf("save state to slot %d", slot))
+		m[fmt.Sprintf("<F%d>", slot+stateSlots)] = NewControl(func() { emu.loadState(slot) }, fmt.Sprintf("load state from slot %d", slot))
+	}
+	return m
+}
+
+// statePath returns the file of a state slot, states are stored next to the rom.
+func (emu *Emulator) statePath(slot int) string {
+	return fmt.Sprintf("%s.%d.state", emu.romFile, slot)
+}
+
+func (emu *Emulator) saveState(slot int) {
+	data, err := emu.chip.Snapshot()
+	if err == nil {
+		err = ioutil.WriteFile(emu.statePath(slot), data, 0644)
+	}
+	emu.report(err, fmt.Sprintf("saved state to slot %d", slot))
+}
+
+func (emu *Emulator) loadState(slot int) {
+	data, err := ioutil.ReadFile(emu.statePath(slot))
+	if err == nil {
+		err = emu.chip.Restore(data)
+	}
+	emu.report(err, fmt.Sprintf("loaded state from slot %d", slot))
+}
+
+func (emu *Emulator) report(err error, msg string) {
+	if err != nil {
+		msg = err.Error()
+	}
+	emu.tui.SetMessage(msg)
+}
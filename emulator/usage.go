@@ -2,64 +2,72 @@ package emulator
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"runtime"
+	"io"
+	"sort"
 	"strings"
 )
 
-func usage(name string, c map[string]Control, t map[string]Control) {
-	pUsage, pKey := usagePadding(c, t)
-	fmt.Printf("Usage: %s [FILE]\n\n", "chip8")
-	fmt.Printf("Emulator Controls:\n")
-	fmt.Printf("|" + line(pKey) + "|" + line(pUsage) + "|\n")
-	fmt.Printf("| %-*s| %-*s|\n", pKey, "key", pUsage, "function")
-	fmt.Printf("|" + line(pKey) + "|" + line(pUsage) + "|\n")
-	for key := range c {
-		fmt.Printf("| %-*s| %-*s|\n", pKey, key, pUsage, c[key].usage)
+// Usage writes the controls of the chip, the TUI and the emulator with the keys of the key map and the console
+// commands.
+func Usage(w io.Writer, keys KeyMap, c Chip, t TUI) error {
+	e := &Emulator{}
+	controls, err := remapControls(keys, c.ControlsMap(), t.ControlsMap(), e.ControlsMap())
+	if err != nil {
+		return err
+	}
+	pUsage, pKey := usagePadding(controls...)
+	fmt.Fprintf(w, "Emulator Controls:\n")
+	fmt.Fprintf(w, "|"+line(pKey)+"|"+line(pUsage)+"|\n")
+	fmt.Fprintf(w, "| %-*s| %-*s|\n", pKey, "key", pUsage, "function")
+	for _, c := range controls {
+		fmt.Fprintf(w, "|"+line(pKey)+"|"+line(pUsage)+"|\n")
+		for _, key := range sortedKeys(c) {
+			fmt.Fprintf(w, "| %-*s| %-*s|\n", pKey, key, pUsage, c[key].usage)
+		}
 	}
-	fmt.Printf("|" + line(pKey) + "|" + line(pUsage) + "|\n")
-	for key := range t {
-		fmt.Printf("| %-*s| %-*s|\n", pKey, key, pUsage, t[key].usage)
+	fmt.Fprintf(w, "|"+line(pKey)+"|"+line(pUsage)+"|\n")
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Console Commands (type : in the TUI):\n")
+	all, err := createCommandMap(c.CommandsMap(), t.CommandsMap(), e.CommandsMap())
+	if err != nil {
+		return err
+	}
+	pArgs := 0
+	for _, name := range commandNames(all) {
+		if l := len(name) + len(all[name].args) + 1; l > pArgs {
+			pArgs = l
+		}
 	}
-	fmt.Printf("|" + line(pKey) + "|" + line(pUsage) + "|\n")
+	for _, name := range commandNames(all) {
+		fmt.Fprintf(w, "  %-*s %s\n", pArgs, name+" "+all[name].args, all[name].usage)
+	}
+	return nil
+}
+
+func sortedKeys(c map[string]Control) []string {
+	var keys []string
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func line(amount int) string {
 	return strings.Repeat("-", amount+1)
 }
-func usagePadding(c map[string]Control, t map[string]Control) (int, int) {
+func usagePadding(controls ...map[string]Control) (int, int) {
 	maxLenUsage := 0
 	maxLenKey := 0
-	for key := range c {
-		if maxLenUsage < len(c[key].usage) {
-			maxLenUsage = len(c[key].usage)
-		}
-		if maxLenKey < len(key) {
-			maxLenKey = len(key)
-		}
-	}
-	for key := range t {
-		if maxLenUsage < len(t[key].usage) {
-			maxLenUsage = len(t[key].usage)
-		}
-		if maxLenKey < len(key) {
-			maxLenKey = len(key)
+	for _, c := range controls {
+		for key := range c {
+			if maxLenUsage < len(c[key].usage) {
+				maxLenUsage = len(c[key].usage)
+			}
+			if maxLenKey < len(key) {
+				maxLenKey = len(key)
+			}
 		}
 	}
 	return maxLenUsage + 1, maxLenKey + 1
 }
-func clearTerminal() {
-	if runtime.GOOS == "windows" {
-		cmd := exec.Command("cmd", "/c", "cls")
-		cmd.Stdout = os.Stdout
-		cmd.Run()
-	} else if runtime.GOOS == "linux" {
-		cmd := exec.Command("clear")
-		cmd.Stdout = os.Stdout
-		cmd.Run()
-	} else {
-		log.Println("operating system not supported for clearing terminal")
-	}
-}
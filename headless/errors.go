@@ -0,0 +1,17 @@
+package headless
+
+import "fmt"
+
+type KeyPressError struct {
+	Press string
+}
+
+func (e KeyPressError) Error() string {
+	return fmt.Sprintf("invalid key press %q, expected at:key[:hold]", e.Press)
+}
+
+type LimitError struct{}
+
+func (e LimitError) Error() string {
+	return "headless run needs either a cycle or a frame limit"
+}
@@ -0,0 +1,248 @@
+package headless
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+const (
+	DefaultStepKey  = "<F10>"
+	DefaultFrameKey = "S"
+	DefaultScale    = 8
+)
+
+var (
+	asciiPixels = [4]byte{'.', '#', '+', '@'}
+	pngPalette  = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0x55, 0x55, 0x55, 0xFF},
+	}
+)
+
+// KeyPress holds Key down from cycle or frame At for Hold cycles or frames.
+type KeyPress struct {
+	At   int
+	Key  string
+	Hold int
+}
+
+// TUI runs a rom without a terminal by pressing the step or frame step control of the chip,
+// the last drawn frame is written as text or png when the emulator closes it.
+type TUI struct {
+	Cycles   int // cycles to run, either Cycles or Frames is set
+	Frames   int // frames to run
+	Keys     []KeyPress
+	StepKey  string
+	FrameKey string
+	QuitKey  string
+	ASCII    string // file the framebuffer is written to as text, - is stdout
+	PNG      string // file the framebuffer is written to as png
+	Scale    int    // png pixels per chip pixel
+
+	chip       emulator.Chip
+	drawSignal <-chan emulator.Frame
+	keySignal  <-chan []byte
+	events     chan string
+
+	mu    sync.Mutex
+	frame emulator.Frame
+	err   error
+}
+
+func (t *TUI) Init(drawSignal <-chan emulator.Frame, keySignal <-chan []byte, c emulator.Chip) {
+	t.chip = c
+	t.drawSignal = drawSignal
+	t.keySignal = keySignal
+	t.events = make(chan string)
+	if t.StepKey == "" {
+		t.StepKey = DefaultStepKey
+	}
+	if t.FrameKey == "" {
+		t.FrameKey = DefaultFrameKey
+	}
+	if t.Scale <= 0 {
+		t.Scale = DefaultScale
+	}
+}
+
+func (t *TUI) Setup() error {
+	if (t.Cycles <= 0) == (t.Frames <= 0) {
+		return LimitError{}
+	}
+	return nil
+}
+
+func (t *TUI) Render() {}
+
+func (t *TUI) ControlsMap() map[string]emulator.Control {
+	return make(map[string]emulator.Control)
+}
+
+func (t *TUI) SetEmuInfo(c emulator.ChipGetter) {}
+
+func (t *TUI) CommandsMap() map[string]emulator.Command {
+	return make(map[string]emulator.Command)
+}
+
+func (t *TUI) SetCommands(names []string) {}
+
+func (t *TUI) SetMessage(msg string) {
+	log.Println(msg)
+}
+
+// KeyEvent replays the scripted keys, steps the chip one cycle or frame at a time and presses the quit key
+// when the limit is reached.
+func (t *TUI) KeyEvent() <-chan string {
+	limit, step := t.Cycles, t.StepKey
+	if t.Frames > 0 {
+		limit, step = t.Frames, t.FrameKey
+	}
+	go func() {
+		for n := 0; n < limit; n++ {
+			for _, k := range t.Keys {
+				if n >= k.At && n < k.At+k.Hold {
+					t.send(k.Key)
+				}
+			}
+			t.send(step)
+		}
+		t.send(t.QuitKey)
+	}()
+	return t.events
+}
+
+// send hands a key to the emulator while taking the frames and key updates the chip blocks on.
+func (t *TUI) send(key string) {
+	for {
+		select {
+		case t.events <- key:
+			return
+		case frame := <-t.drawSignal:
+			t.mu.Lock()
+			t.frame = frame
+			t.mu.Unlock()
+		case <-t.keySignal:
+		}
+	}
+}
+
+// Close writes the last drawn frame to the configured outputs.
+func (t *TUI) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.frame.Pixels == nil {
+		// nothing was drawn, write the blank screen
+		w, h := t.chip.GetScreenSize()
+		t.frame = emulator.Frame{Width: w, Height: h, Pixels: make([]byte, w*h)}
+	}
+	if t.ASCII != "" {
+		t.setErr(writeFile(t.ASCII, func(f io.Writer) error { return WriteASCII(f, t.frame) }))
+	}
+	if t.PNG != "" {
+		t.setErr(writeFile(t.PNG, func(f io.Writer) error { return WritePNG(f, t.frame, t.Scale) }))
+	}
+}
+
+// Frame returns the last drawn frame, after Close a blank screen when nothing was drawn.
+func (t *TUI) Frame() emulator.Frame {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.frame
+}
+
+// Err returns the first error that occurred while writing the framebuffer.
+func (t *TUI) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *TUI) setErr(err error) {
+	if err != nil {
+		log.Println(err)
+		if t.err == nil {
+			t.err = err
+		}
+	}
+}
+
+func WriteASCII(w io.Writer, frame emulator.Frame) error {
+	line := make([]byte, frame.Width+1)
+	line[frame.Width] = '\n'
+	for y := 0; y < frame.Height; y++ {
+		for x := 0; x < frame.Width; x++ {
+			line[x] = asciiPixels[frame.Pixels[x+y*frame.Width]&0x3]
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WritePNG(w io.Writer, frame emulator.Frame, scale int) error {
+	palette := frame.Palette
+	if palette == nil {
+		palette = pngPalette
+	}
+	img := image.NewPaletted(image.Rect(0, 0, frame.Width*scale, frame.Height*scale), palette)
+	for y := 0; y < frame.Height*scale; y++ {
+		for x := 0; x < frame.Width*scale; x++ {
+			img.SetColorIndex(x, y, frame.Pixels[x/scale+(y/scale)*frame.Width]&0x3)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	if name == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ParseKeyPresses parses comma separated at:key[:hold] presses, e.g. "100:5,250:6:30", at and hold are
+// counted in cycles or frames depending on the limit. A press without hold is held for a single step.
+func ParseKeyPresses(s string) ([]KeyPress, error) {
+	var presses []KeyPress
+	if s == "" {
+		return presses, nil
+	}
+	for _, p := range strings.Split(s, ",") {
+		parts := strings.Split(p, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+			return nil, KeyPressError{Press: p}
+		}
+		at, err := strconv.Atoi(parts[0])
+		if err != nil || at < 0 {
+			return nil, KeyPressError{Press: p}
+		}
+		hold := 1
+		if len(parts) == 3 {
+			if hold, err = strconv.Atoi(parts[2]); err != nil || hold < 1 {
+				return nil, KeyPressError{Press: p}
+			}
+		}
+		presses = append(presses, KeyPress{At: at, Key: parts[1], Hold: hold})
+	}
+	return presses, nil
+}
@@ -0,0 +1,82 @@
+package headless
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+// screen is a 64x32 text framebuffer with the rows at its top left corner.
+func screen(rows ...string) string {
+	var b strings.Builder
+	for y := 0; y < 32; y++ {
+		row := ""
+		if y < len(rows) {
+			row = rows[y]
+		}
+		b.WriteString(row + strings.Repeat(".", 64-len(row)) + "\n")
+	}
+	return b.String()
+}
+
+// TestRun runs roms that draw a font digit for a few frames and checks the framebuffer written at the end.
+func TestRun(t *testing.T) {
+	zero := screen("####", "#..#", "#..#", "#..#", "####")
+	for _, tc := range []struct {
+		name           string
+		rom            []byte
+		frames, cycles int
+		keys           []KeyPress
+		want           string
+	}{
+		// v0 := 0, i := hex v0, sprite v0 v0 5, jump 206
+		{"frames", []byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x05, 0x12, 0x06}, 3, 0, nil, zero},
+		{"cycles", []byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x05, 0x12, 0x06}, 0, 10, nil, zero},
+		{"nothing drawn", []byte{0x12, 0x00}, 3, 0, nil, screen()},
+		// v1 := key, i := hex v1, v0 := 0, sprite v0 v0 5, jump 208
+		{"keys", []byte{0xF1, 0x0A, 0xF1, 0x29, 0x60, 0x00, 0xD0, 0x05, 0x12, 0x08},
+			10, 0, []KeyPress{{At: 2, Key: chip8.KeypadQWERTY.Keys()[5], Hold: 2}},
+			screen("####", "#...", "####", "...#", "####")},
+	} {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "test.ch8")
+		if err := ioutil.WriteFile(file, tc.rom, 0644); err != nil {
+			t.Fatal(err)
+		}
+		h := &TUI{Frames: tc.frames, Cycles: tc.cycles, Keys: tc.keys, QuitKey: "<C-c>", ASCII: filepath.Join(dir, "screen.txt")}
+		emu, err := emulator.CreateEmulator(file, h.QuitKey, nil, chip8.New(), h)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		emu.Run()
+		if err := h.Err(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, err := ioutil.ReadFile(h.ASCII)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: framebuffer\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseKeyPresses(t *testing.T) {
+	presses, err := ParseKeyPresses("100:5,250:<F5>:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KeyPress{{100, "5", 1}, {250, "<F5>", 30}}; len(presses) != 2 || presses[0] != want[0] || presses[1] != want[1] {
+		t.Errorf("parsed %v, want %v", presses, want)
+	}
+	for _, s := range []string{"5", "100:", "x:5", "-1:5", "100:5:0", "100:5:x", "100:5:1:2", "100:5,"} {
+		if _, err := ParseKeyPresses(s); err == nil {
+			t.Errorf("%q was parsed", s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+)
+
+// info is the info command, it shows what the emulator knows about roms before running them.
+func info(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	romdb := fs.String("romdb", "", "programs.json of the community CHIP-8 database whose roms replace the bundled ones")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 info [OPTIONS] ROM...\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	db, err := chip8.NewROMDatabase()
+	if err != nil {
+		return err
+	}
+	if *romdb != "" {
+		if err := db.ReadPrograms(*romdb); err != nil {
+			return err
+		}
+	}
+	for i, file := range fs.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := writeInfo(os.Stdout, db, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeInfo(w io.Writer, db *chip8.ROMDatabase, file string) error {
+	rf, err := chip8.OpenROM(file)
+	if err != nil {
+		return err
+	}
+	hash := chip8.HashROM(rf.Data)
+	fmt.Fprintf(w, "File:      %s\n", rf.Name)
+	fmt.Fprintf(w, "Size:      %d bytes\n", len(rf.Data))
+	fmt.Fprintf(w, "SHA-1:     %s\n", hash)
+	var fits []string
+	var invalid error
+	for _, name := range chip8.VariantNames() {
+		v, _ := chip8.ParseVariant(name)
+		if err := chip8.ValidateROM(rf.Data, v, chip8.DefaultLoadAddress); err == nil {
+			fits = append(fits, name)
+		} else if invalid == nil || errors.As(err, new(chip8.ROMTooLargeError)) {
+			invalid = err
+		}
+	}
+	if len(fits) > 0 {
+		fmt.Fprintf(w, "Fits:      %s\n", strings.Join(fits, ", "))
+	} else {
+		fmt.Fprintf(w, "Invalid:   %v\n", invalid)
+	}
+	if rf.Cartridge != nil {
+		o := rf.Cartridge.Options
+		fmt.Fprintf(w, "Cartridge: %s, %s, %d Hz, %d labels\n", o.Variant(), o.Quirks(), o.Speed(), len(rf.Labels))
+	}
+	if _, err := os.Stat(chip8.SymbolsFile(file)); err == nil {
+		fmt.Fprintf(w, "Symbols:   %s\n", chip8.SymbolsFile(file))
+	}
+	rom, ok := db.Lookup(hash)
+	if !ok {
+		fmt.Fprintf(w, "Database:  not found\n")
+		return nil
+	}
+	fmt.Fprintf(w, "Title:     %s\n", rom.Name())
+	if p, ok := db.Platform(rom); ok {
+		fmt.Fprintf(w, "Platform:  %s (%s)\n", p.Name, p.ID)
+		fmt.Fprintf(w, "Quirks:    %s\n", rom.Quirks(p))
+		fmt.Fprintf(w, "Speed:     %d Hz\n", rom.Speed(p))
+	} else {
+		fmt.Fprintf(w, "Platform:  unsupported (%s)\n", strings.Join(rom.Platforms, ", "))
+	}
+	if len(rom.Keys) > 0 {
+		var keys []string
+		for _, name := range []string{"up", "down", "left", "right", "a", "b"} {
+			if key, ok := rom.Keys[name]; ok {
+				keys = append(keys, fmt.Sprintf("%s %X", name, key))
+			}
+		}
+		fmt.Fprintf(w, "Keys:      %s\n", strings.Join(keys, ", "))
+	}
+	return nil
+}
@@ -1,20 +1,65 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
-
-	"github.com/MickLuypaerts/chip8Emu/chip8"
-	"github.com/MickLuypaerts/chip8Emu/emulator"
-	"github.com/MickLuypaerts/chip8Emu/view"
 )
 
+const quitKey = "<C-c>"
+
+// exitStatus is returned by commands that end with a non zero exit status without an error to report.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// commands are the subcommands of chip8, run is used when the first argument is not one of them.
+var commands = map[string]func(args []string) error{
+	"run":    run,
+	"test":   test,
+	"info":   info,
+	"disasm": disasm,
+	"asm":    asm,
+	"tracediff": func(args []string) error {
+		diverged, err := tracediff(args)
+		if err == nil && diverged {
+			return exitStatus(1)
+		}
+		return err
+	},
+}
+
 func main() {
-	chip := new(chip8.Chip8)
-	tui := new(view.TUI)
-	emu, err := emulator.CreateEmulator(os.Args, "q", chip, tui)
-	if err != nil {
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			usage(os.Stdout)
+			return
+		}
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		}
+	}
+	if err := commands[name](args); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
 		log.Fatal(err)
 	}
-	emu.Run()
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: chip8 [run] [OPTIONS] FILE       run a rom in the terminal or headless\n")
+	fmt.Fprintf(w, "       chip8 test [OPTIONS] ROM         run a rom headless and compare the screen with the expected one\n")
+	fmt.Fprintf(w, "       chip8 info [OPTIONS] ROM...      show the size, sha1 and rom database entry of roms\n")
+	fmt.Fprintf(w, "       chip8 disasm [OPTIONS] FILE      disassemble a rom\n")
+	fmt.Fprintf(w, "       chip8 asm [OPTIONS] FILE         assemble an Octo source\n")
+	fmt.Fprintf(w, "       chip8 tracediff [OPTIONS] A B    find the first divergence of two traces\n\n")
+	fmt.Fprintf(w, "chip8 COMMAND --help lists the options of a command, chip8 run lists the controls.\n")
 }
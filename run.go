@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+	"github.com/MickLuypaerts/chip8Emu/config"
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+	"github.com/MickLuypaerts/chip8Emu/headless"
+	"github.com/MickLuypaerts/chip8Emu/view"
+)
+
+// breakFlags collects the --break flags, the flag can be repeated.
+type breakFlags []string
+
+func (b *breakFlags) String() string {
+	return strings.Join(*b, ", ")
+}
+
+func (b *breakFlags) Set(s string) error {
+	*b = append(*b, s)
+	return nil
+}
+
+// machineFlags are the flags of the run and test commands that set up the machine.
+type machineFlags struct {
+	fs *flag.FlagSet
+
+	variant     *string
+	quirks      *string
+	hz          *int
+	ipf         *int
+	seed        *uint64
+	loadAddr    *string
+	onError     *string
+	rewind      *int
+	rewindMem   *int
+	keypad      *string
+	configFile  *string
+	romdb       *string
+	symFile     *string
+	traceFile   *string
+	traceFormat *string
+	traceRange  *string
+	breaks      breakFlags
+
+	cycles *int
+	frames *int
+	keys   *string
+}
+
+func newMachineFlags(fs *flag.FlagSet) *machineFlags {
+	m := &machineFlags{fs: fs}
+	m.variant = fs.String("variant", chip8.VariantChip8.String(), fmt.Sprintf("machine variant (%s)", strings.Join(chip8.VariantNames(), ", ")))
+	m.quirks = fs.String("quirks", "", fmt.Sprintf("quirks preset (%s) with optional comma separated overrides, e.g. schip,jump=false (default depends on the variant)", strings.Join(chip8.QuirksPresetNames(), ", ")))
+	m.hz = fs.Int("hz", chip8.DefaultSpeed, "clock speed in instructions per second")
+	m.ipf = fs.Int("ipf", 0, "clock speed in instructions per 60 Hz frame, overrides --hz")
+	m.seed = fs.Uint64("seed", 0, "seed of the random number generator, runs with the same seed and keys repeat (default a random seed)")
+	m.loadAddr = fs.String("load-addr", "200", "hexadecimal address the rom is loaded at and starts from, 600 for ETI-660 roms")
+	m.onError = fs.String("on-error", chip8.PolicyHalt.String(), fmt.Sprintf("what an unknown opcode, stack overflow or memory access past the end does (%s)", strings.Join(chip8.ErrorPolicyNames(), ", ")))
+	m.rewind = fs.Int("rewind", chip8.DefaultRewindSize, "number of instructions that can be rewound, 0 disables rewinding")
+	m.rewindMem = fs.Int("rewind-memory", chip8.DefaultRewindMemory>>20, "MiB the memory and pixel changes of the rewound instructions may use")
+	m.keypad = fs.String("keypad", chip8.KeypadQWERTY.String(), fmt.Sprintf("host keys of the hexadecimal keypad (%s)", strings.Join(chip8.KeypadLayoutNames(), ", ")))
+	m.configFile = fs.String("config", "", "configuration file (default config.json in the chip8 directory of the user config directory)")
+	m.romdb = fs.String("romdb", "", "programs.json of the community CHIP-8 database whose roms replace the bundled ones, none disables the lookup")
+	m.symFile = fs.String("sym", "", "symbol file with the labels of the rom (default the .sym file next to the rom if there is one)")
+	m.traceFile = fs.String("trace", "", "write every executed instruction to the file, t toggles tracing")
+	m.traceFormat = fs.String("trace-format", chip8.TraceText.String(), fmt.Sprintf("trace file format (%s)", strings.Join(chip8.TraceFormatNames(), ", ")))
+	m.traceRange = fs.String("trace-range", "", "only trace instructions in the hexadecimal address range, e.g. 200-2FF")
+	fs.Var(&m.breaks, "break", "breakpoint, can be repeated: ADDR, ADDR if v3 == 10, read|write|access ADDR or op DXYN (hexadecimal)")
+	m.cycles = fs.Int("cycles", 0, "headless: number of cycles to run")
+	m.frames = fs.Int("frames", 0, "headless: number of frames to run")
+	m.keys = fs.String("keys", "", "headless: comma separated key presses at:key[:hold] counted in cycles or frames, keypad keys by their hex digit, e.g. 100:5,250:6:30")
+	return m
+}
+
+// isSet reports whether the flag was given on the command line or set from the configuration file.
+func (m *machineFlags) isSet(name string) bool {
+	set := false
+	m.fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// newChip creates the machine for the rom file.
+func (m *machineFlags) newChip(file string) (*chip8.Chip8, config.Settings, error) {
+	settings, err := m.loadConfig(file)
+	if err != nil {
+		return nil, settings, err
+	}
+	v, err := chip8.ParseVariant(*m.variant)
+	if err != nil {
+		return nil, settings, err
+	}
+	if *m.quirks == "" {
+		*m.quirks = v.DefaultQuirks()
+	}
+	q, err := chip8.ParseQuirks(*m.quirks)
+	if err != nil {
+		return nil, settings, err
+	}
+	chip := chip8.New()
+	chip.SetVariant(v)
+	chip.SetQuirks(q)
+	if *m.ipf > 0 {
+		*m.hz = *m.ipf * 60
+	}
+	chip.SetSpeed(*m.hz)
+	if m.isSet("seed") {
+		chip.SetSeed(*m.seed)
+	}
+	chip.SetRewindSize(*m.rewind)
+	chip.SetRewindMemory(*m.rewindMem << 20)
+	layout, err := chip8.ParseKeypadLayout(*m.keypad)
+	if err != nil {
+		return nil, settings, err
+	}
+	chip.SetKeypadLayout(layout)
+	addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(*m.loadAddr), "0x"), 16, 16)
+	if err != nil {
+		return nil, settings, fmt.Errorf("invalid --load-addr: %s", *m.loadAddr)
+	}
+	chip.SetLoadAddress(uint16(addr))
+	if settings.Palette != nil {
+		p, err := chip8.ParsePalette(settings.Palette)
+		if err != nil {
+			return nil, settings, err
+		}
+		chip.SetPalette(p)
+	}
+	if err := m.setupROMDatabase(chip, settings.Palette != nil); err != nil {
+		return nil, settings, err
+	}
+	policy, err := chip8.ParseErrorPolicy(*m.onError)
+	if err != nil {
+		return nil, settings, err
+	}
+	chip.SetErrorPolicy(policy)
+	if *m.symFile == "" {
+		if _, err := os.Stat(chip8.SymbolsFile(file)); err == nil {
+			*m.symFile = chip8.SymbolsFile(file)
+		}
+	}
+	if *m.symFile != "" {
+		if err := chip.LoadSymbols(*m.symFile); err != nil {
+			return nil, settings, err
+		}
+	}
+	if err := m.setupTrace(chip); err != nil {
+		return nil, settings, err
+	}
+	for _, b := range m.breaks {
+		if err := chip.AddBreak(b); err != nil {
+			return nil, settings, err
+		}
+	}
+	return chip, settings, nil
+}
+
+// setupTrace sets the trace range and format and starts tracing when --trace is set.
+func (m *machineFlags) setupTrace(chip *chip8.Chip8) error {
+	format, err := chip8.ParseTraceFormat(*m.traceFormat)
+	if err != nil {
+		return err
+	}
+	if *m.traceRange != "" {
+		from, to, err := chip8.ParseTraceRange(*m.traceRange)
+		if err != nil {
+			return err
+		}
+		chip.SetTraceRange(from, to)
+	}
+	if *m.traceFile == "" {
+		chip.SetTraceFormat(format)
+		return nil
+	}
+	return chip.StartTrace(*m.traceFile, format)
+}
+
+// loadConfig reads the configuration file and uses its settings for the rom as the values of the flags that are not
+// set on the command line.
+func (m *machineFlags) loadConfig(file string) (config.Settings, error) {
+	var conf *config.Config
+	var err error
+	if *m.configFile != "" {
+		conf, err = config.Read(*m.configFile)
+	} else {
+		conf, err = config.Load()
+	}
+	if err != nil {
+		return config.Settings{}, err
+	}
+	settings := conf.Settings
+	if rf, err := chip8.OpenROM(file); err == nil {
+		settings = conf.ForROM(rf.Name, chip8.HashROM(rf.Data))
+	}
+	values := map[string]string{
+		"variant":   settings.Variant,
+		"quirks":    settings.Quirks,
+		"load-addr": settings.LoadAddr,
+		"on-error":  settings.OnError,
+		"keypad":    settings.Keypad,
+	}
+	if settings.Hz > 0 {
+		values["hz"] = strconv.Itoa(settings.Hz)
+	}
+	if settings.IPF > 0 && !m.isSet("hz") {
+		values["ipf"] = strconv.Itoa(settings.IPF)
+	}
+	if settings.Rewind != nil {
+		values["rewind"] = strconv.Itoa(*settings.Rewind)
+	}
+	for name, value := range values {
+		if value != "" && !m.isSet(name) {
+			if err := m.fs.Set(name, value); err != nil {
+				return config.Settings{}, err
+			}
+		}
+	}
+	return settings, nil
+}
+
+// setupROMDatabase looks the roms up in the rom database, the settings given on the command line or in the
+// configuration file are kept.
+func (m *machineFlags) setupROMDatabase(chip *chip8.Chip8, palette bool) error {
+	if *m.romdb == "none" {
+		return nil
+	}
+	db, err := chip8.NewROMDatabase()
+	if err != nil {
+		return err
+	}
+	if *m.romdb != "" {
+		if err := db.ReadPrograms(*m.romdb); err != nil {
+			return err
+		}
+	}
+	settings := chip8.DatabaseAll
+	m.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "variant":
+			settings &^= chip8.DatabaseVariant
+		case "quirks":
+			settings &^= chip8.DatabaseQuirks
+		case "hz", "ipf":
+			settings &^= chip8.DatabaseSpeed
+		case "load-addr":
+			settings &^= chip8.DatabaseLoadAddress
+		}
+	})
+	if palette {
+		settings &^= chip8.DatabaseColors
+	}
+	chip.SetROMDatabase(db, settings)
+	return nil
+}
+
+// newHeadless creates a headless TUI that presses the step, frame step and quit controls at the keys of the
+// configuration, the keypad keys of --keys are pressed at their host keys.
+func (m *machineFlags) newHeadless(settings config.Settings) (*headless.TUI, error) {
+	presses, err := headless.ParseKeyPresses(*m.keys)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := chip8.ParseKeypadLayout(*m.keypad)
+	if err != nil {
+		return nil, err
+	}
+	keypad := layout.Keys()
+	for i := range presses {
+		if key, err := strconv.ParseUint(presses[i].Key, 16, 4); err == nil && len(presses[i].Key) == 1 {
+			presses[i].Key = keypad[key]
+		}
+		presses[i].Key = mappedKey(settings.Keys, presses[i].Key)
+	}
+	return &headless.TUI{
+		Cycles:   *m.cycles,
+		Frames:   *m.frames,
+		Keys:     presses,
+		StepKey:  mappedKey(settings.Keys, headless.DefaultStepKey),
+		FrameKey: mappedKey(settings.Keys, headless.DefaultFrameKey),
+		QuitKey:  quitKeyOf(settings),
+	}, nil
+}
+
+// mappedKey is the key the configuration moved the control of key to.
+func mappedKey(keys emulator.KeyMap, key string) string {
+	if to, ok := keys[key]; ok {
+		return to
+	}
+	return key
+}
+
+func quitKeyOf(settings config.Settings) string {
+	if settings.QuitKey != "" {
+		return settings.QuitKey
+	}
+	return quitKey
+}
+
+// run is the run command, it runs a rom in the TUI or headless.
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	m := newMachineFlags(fs)
+	headlessRun := fs.Bool("headless", false, "run without a terminal and write the framebuffer at the end, needs --cycles or --frames")
+	asciiFile := fs.String("ascii", "", "headless: write the framebuffer as text to the file, - is stdout (default when --png is not set)")
+	pngFile := fs.String("png", "", "headless: write the framebuffer as png to the file")
+	scale := fs.Int("scale", headless.DefaultScale, "headless: png pixels per chip pixel")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 [run] [OPTIONS] FILE|ARCHIVE.zip[:NAME]|CARTRIDGE.gif\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage(os.Stdout)
+		fmt.Println()
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		fmt.Println()
+		if err := emulator.Usage(os.Stdout, nil, chip8.New(), new(view.TUI)); err != nil {
+			return err
+		}
+		return exitStatus(2)
+	}
+	file := fs.Arg(0)
+	var err error
+	if !*headlessRun {
+		if file, err = chooseROM(file); err != nil {
+			return err
+		}
+	}
+	chip, settings, err := m.newChip(file)
+	if err != nil {
+		return err
+	}
+	var tui emulator.TUI = new(view.TUI)
+	var h *headless.TUI
+	if *headlessRun {
+		if h, err = m.newHeadless(settings); err != nil {
+			return err
+		}
+		h.ASCII, h.PNG, h.Scale = *asciiFile, *pngFile, *scale
+		if h.ASCII == "" && h.PNG == "" {
+			h.ASCII = "-"
+		}
+		tui = h
+	}
+	emu, err := emulator.CreateEmulator(file, quitKeyOf(settings), settings.Keys, chip, tui)
+	if err != nil {
+		return err
+	}
+	emu.Run()
+	if err := chip.CloseTrace(); err != nil {
+		return err
+	}
+	if h != nil && h.Err() != nil {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// test is the test command, it runs a rom headless and compares the framebuffer at the end with the expected one.
+func test(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	m := newMachineFlags(fs)
+	expect := fs.String("expect", "", "file with the expected framebuffer as text, as written by chip8 run --headless --ascii")
+	update := fs.Bool("update", false, "write the framebuffer to the --expect file instead of comparing it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 test [OPTIONS] --expect FILE --cycles N|--frames N ROM\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *expect == "" {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	file := fs.Arg(0)
+	chip, settings, err := m.newChip(file)
+	if err != nil {
+		return err
+	}
+	if !m.isSet("seed") {
+		// CXNN has to return the same numbers every run
+		chip.SetSeed(0)
+	}
+	h, err := m.newHeadless(settings)
+	if err != nil {
+		return err
+	}
+	emu, err := emulator.CreateEmulator(file, h.QuitKey, settings.Keys, chip, h)
+	if err != nil {
+		return err
+	}
+	emu.Run()
+	if err := chip.CloseTrace(); err != nil {
+		return err
+	}
+	var got bytes.Buffer
+	if err := headless.WriteASCII(&got, h.Frame()); err != nil {
+		return err
+	}
+	if *update {
+		return ioutil.WriteFile(*expect, got.Bytes(), 0644)
+	}
+	want, err := ioutil.ReadFile(*expect)
+	if err != nil {
+		return err
+	}
+	if line, ok := firstDifference(want, got.Bytes()); !ok {
+		fmt.Printf("FAIL %s: the framebuffer differs from %s at line %d\n", file, *expect, line)
+		fmt.Printf("want:\n%s\ngot:\n%s", want, got.Bytes())
+		return exitStatus(1)
+	}
+	fmt.Printf("ok   %s\n", file)
+	return nil
+}
+
+// firstDifference compares two texts line by line, it returns the first line that differs and false when they do.
+func firstDifference(a, b []byte) (int, bool) {
+	al, bl := strings.Split(string(a), "\n"), strings.Split(string(b), "\n")
+	for i := 0; i < len(al) || i < len(bl); i++ {
+		if i >= len(al) || i >= len(bl) || strings.TrimRight(al[i], "\r") != bl[i] {
+			return i + 1, false
+		}
+	}
+	return 0, true
+}
+
+// chooseROM asks which rom to run when the file is a zip archive with several roms and stdin is a terminal.
+func chooseROM(file string) (string, error) {
+	archive, entry := chip8.SplitArchivePath(file)
+	if !chip8.IsArchive(file) || entry != "" {
+		return file, nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return file, nil
+	}
+	roms, err := chip8.ArchiveROMs(archive)
+	if err != nil || len(roms) < 2 {
+		// the loader reports the error or loads the only rom
+		return file, nil
+	}
+	for i, rom := range roms {
+		fmt.Printf("%3d  %s\n", i+1, rom)
+	}
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("rom to run (1-%d): ", len(roms))
+		line, err := in.ReadString('\n')
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && n >= 1 && n <= len(roms) {
+			return archive + ":" + roms[n-1], nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
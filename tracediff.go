@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/MickLuypaerts/chip8Emu/chip8"
+)
+
+// tracediff is the tracediff command, it compares two traces record by record and prints the first divergence with
+// the records around it. It reports whether the traces diverged.
+func tracediff(args []string) (bool, error) {
+	fs := flag.NewFlagSet("tracediff", flag.ExitOnError)
+	context := fs.Int("context", 5, "records shown before and after the divergence")
+	timers := fs.Bool("timers", false, "also compare the delay and sound timers")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 tracediff [OPTIONS] A.trace B.trace\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return false, exitStatus(2)
+	}
+	a, closeA, err := openTrace(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	defer closeA()
+	b, closeB, err := openTrace(fs.Arg(1))
+	if err != nil {
+		return false, err
+	}
+	defer closeB()
+
+	n, d, err := chip8.CompareTraces(a, b, *context, *timers)
+	var readErr chip8.TraceReadError
+	if errors.As(err, &readErr) {
+		return false, fmt.Errorf("%s: %w", fs.Arg(readErr.Trace), readErr.Err)
+	}
+	if err != nil {
+		return false, err
+	}
+	if d == nil {
+		fmt.Printf("traces are identical (%d records)\n", n)
+		return false, nil
+	}
+	if d.Short >= 0 {
+		fmt.Printf("%s ends after %d records\n", fs.Arg(d.Short), n)
+	} else {
+		fmt.Printf("traces diverge at record %d (cycle %d / %d):\n", d.Record, d.After[0][0].Cycle, d.After[1][0].Cycle)
+		for _, diff := range d.Diffs {
+			fmt.Printf("    %s\n", diff)
+		}
+	}
+	printDivergence(d)
+	return true, nil
+}
+
+func openTrace(file string) (*chip8.TraceReader, func() error, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := chip8.NewTraceReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return t, f.Close, nil
+}
+
+// printDivergence prints the records both traces agree on, then the diverging records and the ones after them
+// with - for trace a and + for trace b.
+func printDivergence(d *chip8.TraceDivergence) {
+	fmt.Println()
+	for _, r := range d.Before {
+		fmt.Printf("  %s\n", r)
+	}
+	for i, prefix := range []string{"-", "+"} {
+		for _, r := range d.After[i] {
+			fmt.Printf("%s %s\n", prefix, r)
+		}
+		if d.End[i] {
+			fmt.Printf("%s (end of trace)\n", prefix)
+		}
+	}
+}
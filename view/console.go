@@ -0,0 +1,181 @@
+package view
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+)
+
+const consoleKey = ":"
+
+// console is the command line opened with :, it is shown in the INFO panel and the entered line is passed to the
+// emulator as a key event starting with :.
+type console struct {
+	active   bool
+	line     string
+	history  []string
+	histPos  int    // position while browsing the history, len(history) is the line being typed
+	hint     string // the completions of the last tab
+	commands []string
+}
+
+func (c *console) open() {
+	c.active = true
+	c.line = ""
+	c.hint = ""
+	c.histPos = len(c.history)
+}
+
+// handle edits the line with a key, it returns the line when it was entered.
+func (c *console) handle(key string) (string, bool) {
+	switch key {
+	case "<Enter>":
+		c.active = false
+		line := strings.TrimSpace(c.line)
+		if line != "" && (len(c.history) == 0 || c.history[len(c.history)-1] != line) {
+			c.history = append(c.history, line)
+		}
+		return line, line != ""
+	case "<Escape>", "<C-c>":
+		c.active = false
+	case "<Backspace>", "<C-<Backspace>>":
+		if c.line == "" {
+			c.active = false
+		} else {
+			r := []rune(c.line)
+			c.line = string(r[:len(r)-1])
+		}
+	case "<Space>":
+		c.line += " "
+	case "<Tab>":
+		c.complete()
+	case "<Up>":
+		if c.histPos > 0 {
+			c.histPos--
+			c.line = c.history[c.histPos]
+		}
+	case "<Down>":
+		if c.histPos < len(c.history) {
+			c.histPos++
+			c.line = ""
+			if c.histPos < len(c.history) {
+				c.line = c.history[c.histPos]
+			}
+		}
+	default:
+		if len([]rune(key)) == 1 {
+			c.line += key
+		}
+	}
+	return "", false
+}
+
+// complete completes the command name, when several commands match the line is completed as far as they agree.
+func (c *console) complete() {
+	if strings.Contains(c.line, " ") {
+		return
+	}
+	var matches []string
+	for _, name := range c.commands {
+		if strings.HasPrefix(name, c.line) {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		c.hint = "no matching command"
+	case 1:
+		c.line = matches[0] + " "
+		c.hint = ""
+	default:
+		prefix := matches[0]
+		for _, m := range matches[1:] {
+			for !strings.HasPrefix(m, prefix) {
+				prefix = prefix[:len(prefix)-1]
+			}
+		}
+		c.line = prefix
+		c.hint = strings.Join(matches, " ")
+	}
+}
+
+func (c *console) rows() []string {
+	rows := []string{consoleKey + c.line + "_"}
+	if c.hint != "" {
+		rows = append(rows, c.hint)
+	}
+	return rows
+}
+
+// consoleEvent passes a key to the console when it is open or opened by the key, otherwise the key is returned
+// to be handled by the emulator.
+func (t *TUI) consoleEvent(key string) (string, bool) {
+	var event string
+	forward := false
+	update(func() {
+		switch {
+		case t.console.active:
+			if line, ok := t.console.handle(key); ok {
+				event, forward = consoleKey+line, true
+			}
+		case key == consoleKey:
+			t.console.open()
+		default:
+			event, forward = key, true
+			return
+		}
+		t.setProgStatsRows()
+	}, t.lProgStats)
+	return event, forward
+}
+
+func (t *TUI) SetCommands(names []string) {
+	update(func() { t.console.commands = names })
+}
+
+// CommandsMap returns the console commands of the TUI.
+func (t *TUI) CommandsMap() map[string]emulator.Command {
+	m := make(map[string]emulator.Command)
+	m["list"] = emulator.NewCommand(t.listCommand, "ADDR", "show the disassembly from ADDR, <Home> follows the PC again")
+	m["mem"] = emulator.NewCommand(t.memCommand, "ADDR", "show the memory at ADDR")
+	return m
+}
+
+func (t *TUI) listCommand(args []string) (string, error) {
+	addr, err := parseAddr("list", args)
+	if err != nil {
+		return "", err
+	}
+	update(func() {
+		t.disasmFollow = false
+		t.disasmAddr = addr
+		t.lDisasm.SelectedRow = 0
+		t.updateDisasmRows()
+	}, t.lDisasm)
+	return fmt.Sprintf("listing 0x%03X", addr), nil
+}
+
+func (t *TUI) memCommand(args []string) (string, error) {
+	addr, err := parseAddr("mem", args)
+	if err != nil {
+		return "", err
+	}
+	update(func() {
+		row := int(addr) / lMemRowLength
+		if row >= len(t.lMem.Rows) {
+			row = len(t.lMem.Rows) - 1
+		}
+		t.lMem.SelectedRow = row
+	}, t.lMem)
+	return fmt.Sprintf("memory at 0x%03X", addr), nil
+}
+
+func parseAddr(command string, args []string) (uint16, error) {
+	if len(args) != 1 {
+		return 0, emulator.CommandArgsError{Name: command, Args: "ADDR"}
+	}
+	addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(args[0]), "0x"), 16, 16)
+	return uint16(addr), err
+}
@@ -2,13 +2,18 @@ package view
 
 import "github.com/MickLuypaerts/chip8Emu/emulator"
 
-func (t TUI) ControlsMap() map[string]emulator.Control {
+func (t *TUI) ControlsMap() map[string]emulator.Control {
 	m := make(map[string]emulator.Control)
 	m["j"] = emulator.NewControl(func() { scrollDown(t.lMem) }, "Mem map down")
-	m["<Down>"] = emulator.NewControl(func() { scrollDown(t.lMem) }, "Mem map down")
 	m["k"] = emulator.NewControl(func() { scrollUp(t.lMem) }, "Mem map up")
-	m["<Up>"] = emulator.NewControl(func() { scrollUp(t.lMem) }, "Mem map up")
 	m["g"] = emulator.NewControl(func() { scrollTop(t.lMem) }, "Mem map top")
 	m["G"] = emulator.NewControl(func() { scrollBottom(t.lMem) }, "Mem map bottom")
+	m["<PageDown>"] = emulator.NewControl(func() { update(func() { t.pageDisasm(1) }, t.lDisasm) }, "Disassembly page down")
+	m["<PageUp>"] = emulator.NewControl(func() { update(func() { t.pageDisasm(-1) }, t.lDisasm) }, "Disassembly page up")
+	m["]"] = emulator.NewControl(func() { update(func() { t.moveDisasmCursor(1) }, t.lDisasm) }, "Disassembly next instruction")
+	m["["] = emulator.NewControl(func() { update(func() { t.moveDisasmCursor(-1) }, t.lDisasm) }, "Disassembly previous instruction")
+	m["<Tab>"] = emulator.NewControl(func() { update(t.followDisasmTarget, t.lDisasm) }, "Disassembly go to jump/call target")
+	m["<Home>"] = emulator.NewControl(func() { update(t.followPC, t.lDisasm) }, "Disassembly follow PC")
+	m["B"] = emulator.NewControl(t.toggleSelectedBreakpoint, "Disassembly toggle breakpoint")
 	return m
 }
@@ -0,0 +1,140 @@
+package view
+
+import (
+	"fmt"
+
+	"github.com/MickLuypaerts/chip8Emu/emulator"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+const defaultDisasmRows = 16 // rows before the grid gave the panel its size
+
+func (t *TUI) initLDisasm(c emulator.Chip) {
+	t.toggleBreakpoint = c.ToggleBreakpoint
+	t.lDisasm = widgets.NewList()
+	t.lDisasm.Title = "Disassembly"
+	t.lDisasm.TextStyle = ui.NewStyle(ui.ColorYellow)
+	t.lDisasm.WrapText = false
+	t.lDisasm.SelectedRowStyle = ui.NewStyle(ui.ColorBlack, ui.ColorYellow)
+	t.disassemble = c.Disassemble
+	t.disasmFollow = true
+	t.pc = c.EmulatorInfo().ProgramCount()
+	t.updateDisasmRows()
+}
+
+func (t *TUI) disasmRows() int {
+	if n := t.lDisasm.Inner.Dy(); n > 0 {
+		return n
+	}
+	return defaultDisasmRows
+}
+
+// updateDisasmRows disassembles the instructions shown in lDisasm from the memory shown in lMem. While following
+// the PC the current instruction is kept in the upper third of the panel and selected.
+func (t *TUI) updateDisasmRows() {
+	n := t.disasmRows()
+	if t.disasmFollow {
+		t.disasmAddr = 0
+		if before := uint16(2 * (n / 3)); t.pc > before {
+			t.disasmAddr = t.pc - before
+		}
+	}
+	t.disasmLines = t.disasmLines[:0]
+	var rows []string
+	for addr := int(t.disasmAddr); len(rows) < n && addr < len(t.mem); {
+		l := t.disassemble(t.mem, uint16(addr))
+		marker := " "
+		if l.Addr == t.pc {
+			marker = ">"
+			if t.disasmFollow {
+				t.lDisasm.SelectedRow = len(rows)
+			}
+		}
+		bp := " "
+		if l.Breakpoint {
+			bp = "*"
+		}
+		text := l.Text
+		if l.Label != "" {
+			text = l.Label + ": " + text
+		}
+		rows = append(rows, fmt.Sprintf("%s%s%04X %s", marker, bp, l.Addr, text))
+		t.disasmLines = append(t.disasmLines, l)
+		addr += l.Size
+	}
+	t.lDisasm.Rows = rows
+	if t.lDisasm.SelectedRow >= len(rows) {
+		t.lDisasm.SelectedRow = len(rows) - 1
+	}
+	if t.lDisasm.SelectedRow < 0 {
+		t.lDisasm.SelectedRow = 0
+	}
+}
+
+// The functions changing the panel below are called from the controls while holding the render lock.
+
+// scrollDisasm moves the panel by n instructions of 2 bytes and stops following the PC.
+func (t *TUI) scrollDisasm(n int) {
+	addr := int(t.disasmAddr) + 2*n
+	if addr < 0 {
+		addr = 0
+	} else if addr >= len(t.mem) {
+		addr = len(t.mem) - 2
+	}
+	t.disasmFollow = false
+	t.disasmAddr = uint16(addr)
+	t.updateDisasmRows()
+}
+
+func (t *TUI) pageDisasm(pages int) {
+	t.scrollDisasm(pages * t.disasmRows())
+}
+
+// moveDisasmCursor selects the next or previous instruction, the panel scrolls when the selection leaves it.
+func (t *TUI) moveDisasmCursor(n int) {
+	row := t.lDisasm.SelectedRow + n
+	if row < 0 || row >= len(t.lDisasm.Rows) {
+		t.scrollDisasm(n)
+		return
+	}
+	t.disasmFollow = false
+	t.lDisasm.SelectedRow = row
+}
+
+// followDisasmTarget shows the instruction the selected jump or call goes to.
+func (t *TUI) followDisasmTarget() {
+	row := t.lDisasm.SelectedRow
+	if row < 0 || row >= len(t.disasmLines) || !t.disasmLines[row].HasTarget {
+		return
+	}
+	t.disasmFollow = false
+	t.disasmAddr = t.disasmLines[row].Target
+	t.lDisasm.SelectedRow = 0
+	t.updateDisasmRows()
+}
+
+// followPC makes the panel follow the PC again.
+func (t *TUI) followPC() {
+	t.disasmFollow = true
+	t.updateDisasmRows()
+}
+
+// toggleSelectedBreakpoint sets or removes the breakpoint on the selected instruction. The chip is paused while
+// its breakpoints change, which waits for the frame it publishes, so the render lock is not held meanwhile.
+func (t *TUI) toggleSelectedBreakpoint() {
+	var addr uint16
+	selected := false
+	update(func() {
+		row := t.lDisasm.SelectedRow
+		if row >= 0 && row < len(t.disasmLines) {
+			addr, selected = t.disasmLines[row].Addr, true
+		}
+	})
+	if !selected {
+		return
+	}
+	t.toggleBreakpoint(addr)
+	update(t.updateDisasmRows, t.lDisasm)
+}
@@ -0,0 +1,22 @@
+package view
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+func clearTerminal() {
+	if runtime.GOOS == "windows" {
+		cmd := exec.Command("cmd", "/c", "cls")
+		cmd.Stdout = os.Stdout
+		cmd.Run()
+	} else if runtime.GOOS == "linux" {
+		cmd := exec.Command("clear")
+		cmd.Stdout = os.Stdout
+		cmd.Run()
+	} else {
+		log.Println("operating system not supported for clearing terminal")
+	}
+}
@@ -1,47 +1,71 @@
 package view
 
 import (
+	"bytes"
 	"fmt"
 	"image"
-	"os"
+	"image/color"
+	"strings"
 	"sync"
 
 	"github.com/MickLuypaerts/chip8Emu/emulator"
 
 	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/drawille"
 	"github.com/gizak/termui/v3/widgets"
 )
 
 const (
-	xOffSet       = 2
-	yOffSet       = 4
+	canvasDotsX   = 128 // braille dots available for the screen, a 64x32 screen uses a whole 2x4 dot cell per pixel
+	canvasDotsY   = 128
 	lMemRowLength = 16
 )
 
 var (
 	renderMu sync.Mutex
+
+	// planeColors maps the XO-CHIP bitplane combination of a pixel to its color, plane 1 is the only one
+	// CHIP-8 and SUPER-CHIP draw on
+	planeColors = [4]ui.Color{ui.ColorWhite, ui.ColorRed, ui.ColorYellow, ui.ColorGreen}
+
+	// keypadRows are the keys of the COSMAC VIP keypad row by row
+	keypadRows = [4][4]byte{{0x1, 0x2, 0x3, 0xC}, {0x4, 0x5, 0x6, 0xD}, {0x7, 0x8, 0x9, 0xE}, {0xA, 0x0, 0xB, 0xF}}
 )
 
 type TUI struct {
-	lGPR         *widgets.List
-	lKeys        *widgets.List
-	lStack       *widgets.List
-	lMem         *widgets.List
-	lProgStats   *widgets.List
-	canvas       *ui.Canvas
-	screenWidth  int
-	screenHeight int
-	grid         *ui.Grid
-	termWidth    int
-	termHeight   int
-}
-
-func (t *TUI) Init(drawSignal <-chan []byte, keySignal <-chan []byte, c emulator.Chip) {
+	lGPR             *widgets.List
+	lKeys            *widgets.List
+	keypadKeys       []string // host key of every keypad key
+	lStack           *widgets.List
+	lMem             *widgets.List
+	mem              []byte // memory shown in lMem, only the rows that changed are formatted again
+	lDisasm          *widgets.List
+	disasmLines      []emulator.DisasmLine // instructions shown in lDisasm
+	disasmAddr       uint16                // address of the first instruction in lDisasm
+	disasmFollow     bool                  // lDisasm follows the PC until it is scrolled
+	disassemble      func(memory []byte, addr uint16) emulator.DisasmLine
+	toggleBreakpoint func(addr uint16)
+	pc               uint16
+	lProgStats       *widgets.List
+	info             string
+	message          string // last message of the emulator, shown below the info
+	console          console
+	canvas           *ui.Canvas
+	screenWidth      int
+	screenHeight     int
+	grid             *ui.Grid
+	termWidth        int
+	termHeight       int
+}
+
+func (t *TUI) Init(drawSignal <-chan emulator.Frame, keySignal <-chan []byte, c emulator.Chip) {
 	t.initLGPR(c.GetGPRValues)
 	t.initLKeys()
+	t.keypadKeys = c.KeypadKeys()
 	t.initLStack(c.GetStackValues)
 	t.initLMem(c)
-	t.initLProgStats(c.EmulatorInfo)
+	t.initLDisasm(c)
+	t.initLProgStats(c.ROMName(), c.EmulatorInfo)
 	t.initCanvas()
 	t.initTermSize()
 	t.screenWidth, t.screenHeight = c.GetScreenSize()
@@ -51,20 +75,50 @@ func (t *TUI) Init(drawSignal <-chan []byte, keySignal <-chan []byte, c emulator
 			select {
 			case keys := <-keySignal:
 				t.keyInfo(keys)
-			case screen := <-drawSignal:
-				t.updateScreen(screen)
+			case frame := <-drawSignal:
+				t.updateScreen(frame)
 			}
 		}
 	}()
 }
 
+// keyInfo shows the keypad in the layout of the COSMAC VIP keypad, every key with the host key that presses it.
+// Pressed keys are highlighted.
 func (t *TUI) keyInfo(keys []byte) {
-	var keysFormat []string
-	for i := range keys {
-		keysFormat = append(keysFormat, fmt.Sprintf("K%X   %d", i, keys[i]))
+	width := 0
+	for _, k := range t.keypadKeys {
+		if l := len([]rune(shortKey(k))); l > width {
+			width = l
+		}
+	}
+	var rows []string
+	for _, keypadRow := range keypadRows {
+		var cells []string
+		for _, k := range keypadRow {
+			host := ""
+			if int(k) < len(t.keypadKeys) {
+				host = shortKey(t.keypadKeys[k])
+			}
+			cell := fmt.Sprintf("%X=%-*s", k, width, host)
+			if int(k) < len(keys) && keys[k] != 0 {
+				cell = fmt.Sprintf("[%s](fg:black,bg:yellow)", cell)
+			}
+			cells = append(cells, cell)
+		}
+		rows = append(rows, strings.Join(cells, " "))
+	}
+	update(func() { t.lKeys.Rows = rows }, t.lKeys)
+}
+
+// shortKey shortens the names of special keys to three letters, <Enter> is Ent.
+func shortKey(key string) string {
+	if r := []rune(key); len(r) > 2 && r[0] == '<' && r[len(r)-1] == '>' {
+		key = string(r[1 : len(r)-1])
+		if r := []rune(key); len(r) > 3 {
+			key = string(r[:3])
+		}
 	}
-	t.lKeys.Rows = keysFormat
-	render(t.lKeys)
+	return key
 }
 
 func (t *TUI) initLGPR(getGPRValues func() []string) {
@@ -78,7 +132,7 @@ func (t *TUI) initLGPR(getGPRValues func() []string) {
 
 func (t *TUI) initLKeys() {
 	t.lKeys = widgets.NewList()
-	t.lKeys.Title = "Keys"
+	t.lKeys.Title = "Keypad"
 	t.lKeys.TextStyle = ui.NewStyle(ui.ColorYellow)
 	t.lKeys.WrapText = false
 	t.lKeys.SelectedRowStyle = ui.NewStyle(ui.ColorYellow)
@@ -98,34 +152,46 @@ func (t *TUI) initLMem(c emulator.Chip) {
 	t.lMem.TextStyle = ui.NewStyle(ui.ColorYellow)
 	t.lMem.WrapText = false
 
-	t.lMem.Rows = memoryToTUIMemory(c.GetMemoryValues())
+	t.updateMemoryRows(c.GetMemoryValues())
 	t.setListMemRow(c.EmulatorInfo())
 }
 
+// updateMemoryRows formats the rows of memory that changed since the last update, XO-CHIP has 4096 rows
+// which are too many to format every cycle.
+func (t *TUI) updateMemoryRows(memory []byte) {
+	if len(t.mem) != len(memory) {
+		t.lMem.Rows = memoryToTUIMemory(memory)
+		t.mem = append(t.mem[:0], memory...)
+		return
+	}
+	for start := 0; start+lMemRowLength <= len(memory); start += lMemRowLength {
+		end := start + lMemRowLength
+		if !bytes.Equal(t.mem[start:end], memory[start:end]) {
+			t.lMem.Rows[start/lMemRowLength] = memoryRow(start, memory[start:end])
+			copy(t.mem[start:end], memory[start:end])
+		}
+	}
+}
+
 func memoryToTUIMemory(memory []byte) []string {
 	var mem []string
-	var row string
-
-	rowCount := 0
-	columnCount := 0x0
-
-	for i := 0; i < len(memory); i++ {
-		if rowCount == 0 {
-			row = fmt.Sprintf("0x%04X ", columnCount<<4)
-			columnCount++
-		}
-		row += fmt.Sprintf("%02X ", memory[i])
-		rowCount++
-		if rowCount == lMemRowLength {
-			mem = append(mem, row)
-			rowCount = 0
-		}
+	for start := 0; start+lMemRowLength <= len(memory); start += lMemRowLength {
+		mem = append(mem, memoryRow(start, memory[start:start+lMemRowLength]))
 	}
 	return mem
 }
-func (t *TUI) initLProgStats(getProgStats func() emulator.EmulatorInfo) {
+
+func memoryRow(addr int, memory []byte) string {
+	var row strings.Builder
+	fmt.Fprintf(&row, "0x%04X ", addr)
+	for _, b := range memory {
+		fmt.Fprintf(&row, "%02X ", b)
+	}
+	return row.String()
+}
+func (t *TUI) initLProgStats(romName string, getProgStats func() emulator.EmulatorInfo) {
 	t.lProgStats = widgets.NewList()
-	t.lProgStats.Title = fmt.Sprintf("INFO %s", os.Args[1])
+	t.lProgStats.Title = fmt.Sprintf("INFO %s", romName)
 	t.lProgStats.WrapText = true
 	t.lProgStats.Rows = []string{fmt.Sprint(getProgStats())}
 }
@@ -138,14 +204,15 @@ func (t *TUI) initGrid() {
 	t.grid.SetRect(0, 0, t.termWidth, t.termHeight)
 	t.grid.Set(
 		ui.NewRow(2.0/3,
-			ui.NewCol(3.0/4, t.canvas),
+			ui.NewCol(2.25/4, t.canvas),
+			ui.NewCol(0.75/4, t.lDisasm),
 			ui.NewCol(1.0/4, t.lProgStats),
 		),
 		ui.NewRow(1.0/3,
 			ui.NewCol(0.5/4, t.lGPR),
 			ui.NewCol(0.5/4, t.lStack),
-			ui.NewCol(0.5/4, t.lKeys),
-			ui.NewCol(2.5/4, t.lMem),
+			ui.NewCol(0.75/4, t.lKeys),
+			ui.NewCol(2.25/4, t.lMem),
 		),
 	)
 
@@ -155,14 +222,42 @@ func (t *TUI) initTermSize() {
 	t.termWidth, t.termHeight = ui.TerminalDimensions()
 }
 
+// SetEmuInfo is called from the goroutine running the chip, the chip is only read here.
 func (t *TUI) SetEmuInfo(c emulator.ChipGetter) {
-	t.lProgStats.Rows = []string{fmt.Sprint(c.EmulatorInfo())}
-	t.lGPR.Rows = c.GetGPRValues()
-	t.lMem.Rows = memoryToTUIMemory(c.GetMemoryValues())
-	t.setListMemRow(c.EmulatorInfo())
-	t.lStack.Rows = c.GetStackValues()
+	info := c.EmulatorInfo()
+	gpr := c.GetGPRValues()
+	memory := c.GetMemoryValues()
+	stack := c.GetStackValues()
+	title := fmt.Sprintf("INFO %s", c.ROMName())
+	update(func() {
+		t.lProgStats.Title = title
+		t.info = fmt.Sprint(info)
+		t.setProgStatsRows()
+		t.lGPR.Rows = gpr
+		t.updateMemoryRows(memory)
+		t.setListMemRow(info)
+		t.lStack.Rows = stack
+		t.pc = info.ProgramCount()
+		t.updateDisasmRows()
+	}, t.lProgStats, t.lGPR, t.lMem, t.lStack, t.lDisasm)
+}
 
-	render(t.lProgStats, t.lGPR, t.lMem, t.lStack)
+func (t *TUI) SetMessage(msg string) {
+	update(func() {
+		t.message = msg
+		t.setProgStatsRows()
+	}, t.lProgStats)
+}
+
+func (t *TUI) setProgStatsRows() {
+	t.lProgStats.Rows = []string{t.info}
+	if t.message != "" {
+		t.lProgStats.Rows = append(t.lProgStats.Rows, "", t.message)
+	}
+	if t.console.active {
+		t.lProgStats.Rows = append(t.lProgStats.Rows, "")
+		t.lProgStats.Rows = append(t.lProgStats.Rows, t.console.rows()...)
+	}
 }
 
 func (t *TUI) setListMemRow(emulatorInfo emulator.EmulatorInfo) {
@@ -174,44 +269,62 @@ func (t *TUI) setListMemRow(emulatorInfo emulator.EmulatorInfo) {
 	} else {
 		t.lMem.SelectedRow = row
 	}
-	render(t.lMem)
 }
 
-func (t *TUI) updateScreen(screenBuffer []byte) {
-	for y := 0; y < t.screenHeight; y++ {
-		for x := 0; x < t.screenWidth; x++ {
-			if screenBuffer[x+(y*t.screenWidth)] != 0 {
-				t.canvas.SetPoint(image.Pt(x*xOffSet, y*yOffSet), ui.ColorRed)
-			} else {
-				t.canvas.SetPoint(image.Pt(x*xOffSet, y*yOffSet), ui.ColorWhite)
+func (t *TUI) updateScreen(frame emulator.Frame) {
+	if len(frame.Pixels) < frame.Width*frame.Height || frame.Width == 0 || frame.Height == 0 {
+		return
+	}
+	xScale := canvasDotsX / frame.Width
+	yScale := canvasDotsY / frame.Height
+	colors := planeColors
+	for i := 0; i < len(colors) && i < len(frame.Palette); i++ {
+		colors[i] = terminalColor(frame.Palette[i])
+	}
+	// off pixels can only be drawn when a pixel has a braille cell to itself, otherwise they would light up the cell
+	wholeCell := xScale >= 2 && yScale >= 4
+	update(func() {
+		if !wholeCell || frame.Width != t.screenWidth || frame.Height != t.screenHeight {
+			t.screenWidth, t.screenHeight = frame.Width, frame.Height
+			t.canvas.CellMap = make(map[image.Point]drawille.Cell)
+		}
+		for y := 0; y < frame.Height; y++ {
+			for x := 0; x < frame.Width; x++ {
+				p := frame.Pixels[x+(y*frame.Width)] & 0x3
+				if p != 0 || wholeCell {
+					t.canvas.SetPoint(image.Pt(x*xScale, y*yScale), colors[p])
+				}
 			}
 		}
-	}
-	render(t.canvas)
+	}, t.canvas)
+}
+
+// terminalColor is the closest color of the 6x6x6 color cube of 256 color terminals.
+func terminalColor(c color.Color) ui.Color {
+	r, g, b, _ := c.RGBA()
+	level := func(v uint32) int { return int(v*5+0x7FFF) / 0xFFFF }
+	return ui.Color(16 + 36*level(r) + 6*level(g) + level(b))
 }
 
 func scrollDown(l *widgets.List) {
-	l.ScrollDown()
-	render(l)
+	update(l.ScrollDown, l)
 }
 
 func scrollUp(l *widgets.List) {
-	l.ScrollUp()
-	render(l)
+	update(l.ScrollUp, l)
 }
 
 func scrollTop(l *widgets.List) {
-	l.ScrollTop()
-	render(l)
+	update(l.ScrollTop, l)
 }
 
 func scrollBottom(l *widgets.List) {
-	l.ScrollBottom()
-	render(l)
+	update(l.ScrollBottom, l)
 }
 
 func (t TUI) Close() {
 	ui.Close()
+	clearTerminal()
 }
 
 func (t TUI) Render() {
@@ -225,13 +338,15 @@ func (t TUI) Setup() error {
 	return nil
 }
 
-func (t TUI) KeyEvent() <-chan string {
+func (t *TUI) KeyEvent() <-chan string {
 	ch := make(chan string, 1)
 	keyEvents := ui.PollEvents()
 	go func() {
 		for {
 			key := <-keyEvents
-			ch <- key.ID
+			if event, ok := t.consoleEvent(key.ID); ok {
+				ch <- event
+			}
 		}
 	}()
 	return ch
@@ -242,3 +357,11 @@ func render(items ...ui.Drawable) {
 	ui.Render(items...)
 	renderMu.Unlock()
 }
+
+// update changes widgets and renders them, the widgets are changed from the chip, the key and the draw goroutines.
+func update(f func(), items ...ui.Drawable) {
+	renderMu.Lock()
+	f()
+	ui.Render(items...)
+	renderMu.Unlock()
+}